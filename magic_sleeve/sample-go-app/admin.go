@@ -0,0 +1,46 @@
+package main
+
+import (
+	"crypto/subtle"
+	"log"
+	"net/http"
+	"strings"
+)
+
+const redacted = "REDACTED"
+
+// requireAdmin guards admin endpoints with the -admin-token bearer token.
+// Admin endpoints are disabled entirely when no token is configured.
+func requireAdmin(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if config.AdminToken == "" {
+			writeError(w, http.StatusForbidden, "Admin endpoints are disabled")
+			return
+		}
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(config.AdminToken)) != 1 {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			writeError(w, http.StatusUnauthorized, "Invalid admin token")
+			return
+		}
+		next(w, r)
+	}
+}
+
+func adminConfigHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	writeJSON(w, http.StatusOK, config.redacted())
+}
+
+func adminReseedHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	count := seedStore()
+	log.Printf("Store reseeded with %d sample items", count)
+	writeJSON(w, http.StatusOK, map[string]int{"count": count})
+}
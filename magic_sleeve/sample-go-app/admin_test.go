@@ -0,0 +1,65 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+)
+
+func adminRequest(method, target, body string) *http.Request {
+	r := newRequest(method, target, body)
+	r.Header.Set("Authorization", "Bearer s3cret")
+	return r
+}
+
+func TestAdminConfig(t *testing.T) {
+	h := newTestServer(t, "-admin-token", "s3cret", "-max-connections", "1234", "-chaos-delay", "45s")
+	w := serve(h, adminRequest(http.MethodGet, "/admin/config", ""))
+	wantStatus(t, w, http.StatusOK)
+	var got map[string]interface{}
+	decode(t, w, &got)
+	if got["max_connections"] != 1234.0 {
+		t.Errorf("max_connections = %v, want 1234", got["max_connections"])
+	}
+	if got["chaos_delay"] != "45s" {
+		t.Errorf("chaos_delay = %v, want \"45s\"", got["chaos_delay"])
+	}
+	if got["admin_token"] != redacted {
+		t.Errorf("admin_token = %v, want it masked", got["admin_token"])
+	}
+}
+
+func TestAdminConfigNeedsToken(t *testing.T) {
+	h := newTestServer(t, "-admin-token", "s3cret")
+	wantStatus(t, do(h, http.MethodGet, "/admin/config", ""), http.StatusUnauthorized)
+
+	h = newTestServer(t, "-admin-token", "")
+	wantStatus(t, serve(h, adminRequest(http.MethodGet, "/admin/config", "")), http.StatusForbidden)
+}
+
+func TestAdminReseed(t *testing.T) {
+	h := newTestServer(t, "-admin-token", "s3cret")
+	wantStatus(t, do(h, http.MethodDelete, "/api/items/1", ""), http.StatusOK)
+	wantStatus(t, do(h, http.MethodPut, "/api/items/2", `{"name":"Changed","value":-1}`), http.StatusOK)
+	wantStatus(t, do(h, http.MethodPost, "/api/items", `{"name":"Extra"}`), http.StatusCreated)
+
+	w := serve(h, adminRequest(http.MethodPost, "/admin/reseed", ""))
+	wantStatus(t, w, http.StatusOK)
+	var got struct{ Count int }
+	decode(t, w, &got)
+	if got.Count != 3 {
+		t.Errorf("count = %d, want 3", got.Count)
+	}
+
+	w = do(h, http.MethodGet, "/api/items", "")
+	var items []Item
+	decode(t, w, &items)
+	want := []Item{{ID: "1", Name: "Item One", Value: 100}, {ID: "2", Name: "Item Two", Value: 200}, {ID: "3", Name: "Item Three", Value: 300}}
+	if len(items) != len(want) {
+		t.Fatalf("store holds %d items after reseed, want %d", len(items), len(want))
+	}
+	for i, item := range items {
+		if item.ID != want[i].ID || item.Name != want[i].Name || item.Value != want[i].Value {
+			t.Errorf("item %d = %+v, want %+v", i, item, want[i])
+		}
+	}
+}
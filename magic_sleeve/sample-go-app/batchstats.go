@@ -0,0 +1,92 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"sync"
+	"time"
+)
+
+// batchTiming is added to batch responses so bulk importers can tune their
+// batch sizes. It covers the work done after the request body was read, so
+// a slow client upload doesn't skew it.
+type batchTiming struct {
+	TookMS      float64 `json:"took_ms"`
+	ItemsPerSec float64 `json:"items_per_sec"`
+}
+
+type batchStat struct {
+	requests int
+	items    int
+	seconds  float64
+}
+
+// batchStats accumulates batch sizes and durations per operation for
+// /metrics.
+var batchStats = struct {
+	mu  sync.Mutex
+	ops map[string]*batchStat
+}{ops: make(map[string]*batchStat)}
+
+// batchStatsd is the StatsD client batch metrics are sent to, or nil when
+// -statsd-addr is unset.
+var batchStatsd *statsdClient
+
+// finishBatch records a batch of n entries for op that started processing
+// at start and returns the timing to report to the client.
+func finishBatch(op string, n int, start time.Time) batchTiming {
+	took := time.Since(start)
+
+	batchStats.mu.Lock()
+	stat := batchStats.ops[op]
+	if stat == nil {
+		stat = &batchStat{}
+		batchStats.ops[op] = stat
+	}
+	stat.requests++
+	stat.items += n
+	stat.seconds += took.Seconds()
+	batchStats.mu.Unlock()
+
+	if batchStatsd != nil {
+		batchStatsd.histogram("batch."+op+".size", n)
+		batchStatsd.timing("batch."+op+".duration", took)
+	}
+
+	timing := batchTiming{TookMS: math.Round(took.Seconds()*1e6) / 1e3}
+	// A coarse clock can measure a tiny batch as taking no time at all;
+	// dividing by that would give +Inf or NaN, which JSON cannot encode.
+	if took > 0 {
+		timing.ItemsPerSec = math.Round(float64(n)/took.Seconds()*10) / 10
+	}
+	return timing
+}
+
+// writeBatchMetrics writes the batch summaries in the Prometheus text
+// format, ops in name order.
+func writeBatchMetrics(w io.Writer) {
+	batchStats.mu.Lock()
+	defer batchStats.mu.Unlock()
+	ops := make([]string, 0, len(batchStats.ops))
+	for op := range batchStats.ops {
+		ops = append(ops, op)
+	}
+	sort.Strings(ops)
+
+	fmt.Fprintln(w, "# HELP codelabs_batch_size Entries per batch request.")
+	fmt.Fprintln(w, "# TYPE codelabs_batch_size summary")
+	for _, op := range ops {
+		stat := batchStats.ops[op]
+		fmt.Fprintf(w, "codelabs_batch_size_sum{op=%s} %d\n", labelValue(op), stat.items)
+		fmt.Fprintf(w, "codelabs_batch_size_count{op=%s} %d\n", labelValue(op), stat.requests)
+	}
+	fmt.Fprintln(w, "# HELP codelabs_batch_duration_seconds Server-side processing time of batch requests.")
+	fmt.Fprintln(w, "# TYPE codelabs_batch_duration_seconds summary")
+	for _, op := range ops {
+		stat := batchStats.ops[op]
+		fmt.Fprintf(w, "codelabs_batch_duration_seconds_sum{op=%s} %g\n", labelValue(op), stat.seconds)
+		fmt.Fprintf(w, "codelabs_batch_duration_seconds_count{op=%s} %d\n", labelValue(op), stat.requests)
+	}
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBatchTiming(t *testing.T) {
+	h := newTestServer(t)
+	items := make([]Item, 500)
+	for i := range items {
+		items[i] = Item{ID: fmt.Sprint(i + 1), Name: "Bulk"}
+	}
+	body, _ := json.Marshal(items)
+
+	start := time.Now()
+	w := do(h, http.MethodPut, "/api/items?confirm=true", string(body))
+	wall := time.Since(start)
+	wantStatus(t, w, http.StatusOK)
+	var resp struct {
+		Summary     *replaceSummary `json:"summary"`
+		TookMS      *float64        `json:"took_ms"`
+		ItemsPerSec *float64        `json:"items_per_sec"`
+	}
+	decode(t, w, &resp)
+	if resp.Summary == nil || resp.TookMS == nil || resp.ItemsPerSec == nil {
+		t.Fatalf("response %s lacks summary, took_ms or items_per_sec", w.Body.String())
+	}
+	if took := *resp.TookMS; took < 0 || took > float64(wall.Microseconds())/1e3 {
+		t.Errorf("took_ms = %g, want between 0 and the %s the request took", took, wall)
+	}
+	if *resp.TookMS > 0 {
+		// Both fields are rounded, so allow a little slack.
+		if want := 500 / (*resp.TookMS / 1e3); math.Abs(*resp.ItemsPerSec-want) > want*0.01+1 {
+			t.Errorf("items_per_sec = %g, want about %g for 500 items in %gms", *resp.ItemsPerSec, want, *resp.TookMS)
+		}
+	}
+
+	metrics := do(h, http.MethodGet, "/metrics", "").Body.String()
+	for _, want := range []string{
+		`codelabs_batch_size_sum{op="replace"} 500`,
+		`codelabs_batch_size_count{op="replace"} 1`,
+		`codelabs_batch_duration_seconds_count{op="replace"} 1`,
+	} {
+		if !strings.Contains(metrics, want+"\n") {
+			t.Errorf("/metrics is missing %s", want)
+		}
+	}
+}
+
+func TestBatchTimingZeroDuration(t *testing.T) {
+	newTestServer(t)
+	// A start in the future stands in for a clock too coarse to see the
+	// batch take any time.
+	timing := finishBatch("test", 5, time.Now().Add(time.Hour))
+	if timing.ItemsPerSec != 0 {
+		t.Errorf("items_per_sec = %g, want 0", timing.ItemsPerSec)
+	}
+	if _, err := json.Marshal(timing); err != nil {
+		t.Errorf("timing does not encode: %v", err)
+	}
+}
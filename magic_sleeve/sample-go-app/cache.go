@@ -0,0 +1,78 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+)
+
+// collectionViews are the GET /api/items/{id} names that serve a view of the
+// whole collection rather than one item.
+var collectionViews = map[string]bool{"export": true, "histogram": true, "kv": true}
+
+// isSingleItemRead reports whether r reads one item, the only kind of
+// response that is safe for browsers and CDNs to keep.
+func isSingleItemRead(r *http.Request) bool {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return false
+	}
+	if id, ok := strings.CutPrefix(r.URL.Path, "/api/items/"); ok {
+		return id != "" && !collectionViews[id]
+	}
+	id, ok := strings.CutPrefix(r.URL.Path, "/items/")
+	return ok && id != ""
+}
+
+// cacheControlMiddleware sets Cache-Control on item endpoints: single item
+// reads get -item-cache-control, listings get -collection-cache-control and
+// every mutation gets no-store.
+func cacheControlMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case itemRouteMethods(r.URL.Path) == nil:
+		case isSingleItemRead(r):
+			w = &itemCacheWriter{ResponseWriter: w}
+		case r.Method == http.MethodGet || r.Method == http.MethodHead:
+			setCacheControl(w, config.CollectionCacheControl)
+		default:
+			w.Header().Set("Cache-Control", "no-store")
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func setCacheControl(w http.ResponseWriter, value string) {
+	if value != "" {
+		w.Header().Set("Cache-Control", value)
+	}
+}
+
+// itemCacheWriter applies -item-cache-control once the status is known. A
+// 304 carries the same directives as the 200 it revalidates, as RFC 9111
+// requires; errors are never cached.
+type itemCacheWriter struct {
+	http.ResponseWriter
+	wroteHeader bool
+}
+
+func (w *itemCacheWriter) WriteHeader(status int) {
+	if !w.wroteHeader {
+		w.wroteHeader = true
+		if status == http.StatusOK || status == http.StatusNotModified {
+			setCacheControl(w.ResponseWriter, config.ItemCacheControl)
+		} else {
+			w.Header().Set("Cache-Control", "no-store")
+		}
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *itemCacheWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *itemCacheWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
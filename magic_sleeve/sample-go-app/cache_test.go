@@ -0,0 +1,46 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+)
+
+func wantCacheControl(t *testing.T, h http.Handler, r *http.Request, status int, want string) {
+	t.Helper()
+	w := serve(h, r)
+	wantStatus(t, w, status)
+	if got := w.Header().Get("Cache-Control"); got != want {
+		t.Errorf("%s %s: Cache-Control = %q, want %q", r.Method, r.URL, got, want)
+	}
+}
+
+func TestCacheControlDefaults(t *testing.T) {
+	h := newTestServer(t)
+	wantCacheControl(t, h, newRequest(http.MethodGet, "/api/items/1", ""), http.StatusOK, "max-age=60")
+	wantCacheControl(t, h, newRequest(http.MethodGet, "/items/1", ""), http.StatusOK, "max-age=60")
+	wantCacheControl(t, h, newRequest(http.MethodGet, "/api/items", ""), http.StatusOK, "no-store")
+	wantCacheControl(t, h, newRequest(http.MethodGet, "/api/items/export", ""), http.StatusOK, "no-store")
+	wantCacheControl(t, h, newRequest(http.MethodPost, "/api/items", `{"name":"New","value":1}`), http.StatusCreated, "no-store")
+	wantCacheControl(t, h, newRequest(http.MethodPut, "/api/items/1", `{"name":"Changed","value":1}`), http.StatusOK, "no-store")
+	wantCacheControl(t, h, newRequest(http.MethodDelete, "/api/items/2", ""), http.StatusOK, "no-store")
+
+	// Errors on a cacheable route must not be kept.
+	wantCacheControl(t, h, newRequest(http.MethodGet, "/api/items/99", ""), http.StatusNotFound, "no-store")
+}
+
+func TestCacheControlOnNotModified(t *testing.T) {
+	h := newTestServer(t)
+	w := do(h, http.MethodGet, "/api/items/1", "")
+	wantStatus(t, w, http.StatusOK)
+
+	r := newRequest(http.MethodGet, "/api/items/1", "")
+	r.Header.Set("If-None-Match", w.Header().Get("ETag"))
+	wantCacheControl(t, h, r, http.StatusNotModified, "max-age=60")
+}
+
+func TestCacheControlFlags(t *testing.T) {
+	h := newTestServer(t, "-item-cache-control", "private, max-age=5", "-collection-cache-control", "")
+	wantCacheControl(t, h, newRequest(http.MethodGet, "/api/items/1", ""), http.StatusOK, "private, max-age=5")
+	wantCacheControl(t, h, newRequest(http.MethodGet, "/api/items", ""), http.StatusOK, "")
+	wantCacheControl(t, h, newRequest(http.MethodPost, "/api/items", `{"name":"New","value":1}`), http.StatusCreated, "no-store")
+}
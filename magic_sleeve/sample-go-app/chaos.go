@@ -0,0 +1,74 @@
+package main
+
+import (
+	"math/rand"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// chaosRand decides which requests fail. It is seeded from -chaos-seed so a
+// given seed produces the same failure sequence on every run.
+type chaosRand struct {
+	mu  sync.Mutex
+	rng *rand.Rand
+}
+
+func newChaosRand(seed int64) *chaosRand {
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	return &chaosRand{rng: rand.New(rand.NewSource(seed))}
+}
+
+func (c *chaosRand) fail(rate float64) bool {
+	if rate <= 0 {
+		return false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.rng.Float64() < rate
+}
+
+// chaosMiddleware injects artificial latency and 503 errors into responses.
+// It is a no-op unless the server was started with -chaos; the X-Chaos-Delay
+// header then overrides -chaos-delay for a single request.
+func chaosMiddleware(next http.Handler) http.Handler {
+	if !config.Chaos {
+		return next
+	}
+	errs := newChaosRand(config.ChaosSeed)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Keep liveness/readiness probes stable while chaos is on.
+		if r.URL.Path == "/health" || r.URL.Path == "/readyz" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		delay := config.ChaosDelay.Duration
+		if h := r.Header.Get("X-Chaos-Delay"); h != "" {
+			d, err := time.ParseDuration(h)
+			if err != nil || d < 0 {
+				writeError(w, http.StatusBadRequest, "Invalid X-Chaos-Delay header")
+				return
+			}
+			delay = d
+		}
+
+		if delay > 0 {
+			timer := time.NewTimer(delay)
+			select {
+			case <-timer.C:
+			case <-r.Context().Done():
+				timer.Stop()
+				return
+			}
+		}
+
+		if errs.fail(config.ChaosErrorRate) {
+			writeError(w, http.StatusServiceUnavailable, "Chaos: injected failure")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
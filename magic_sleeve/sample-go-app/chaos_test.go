@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestChaosDelayHeader(t *testing.T) {
+	h := newTestServer(t, "-chaos")
+	r := newRequest(http.MethodGet, "/api/items", "")
+	r.Header.Set("X-Chaos-Delay", "50ms")
+
+	start := time.Now()
+	w := serve(h, r)
+	wantStatus(t, w, http.StatusOK)
+	if took := time.Since(start); took < 50*time.Millisecond {
+		t.Errorf("request took %s, want at least 50ms", took)
+	}
+}
+
+func TestChaosDelayHeaderIgnoredWithoutChaos(t *testing.T) {
+	h := newTestServer(t)
+	r := newRequest(http.MethodGet, "/api/items", "")
+	r.Header.Set("X-Chaos-Delay", "10s")
+
+	start := time.Now()
+	wantStatus(t, serve(h, r), http.StatusOK)
+	if took := time.Since(start); took > time.Second {
+		t.Errorf("request took %s; the header must be ignored without -chaos", took)
+	}
+}
+
+func TestChaosDelayStopsWhenClientGoesAway(t *testing.T) {
+	h := newTestServer(t, "-chaos", "-chaos-delay", "10s")
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	r := newRequest(http.MethodGet, "/api/items", "").WithContext(ctx)
+
+	start := time.Now()
+	serve(h, r)
+	if took := time.Since(start); took > time.Second {
+		t.Errorf("request took %s after its context was canceled", took)
+	}
+}
+
+func TestChaosErrorRate(t *testing.T) {
+	for _, tc := range []struct {
+		rate   string
+		status int
+	}{
+		{"1.0", http.StatusServiceUnavailable},
+		{"0.0", http.StatusOK},
+	} {
+		t.Run(tc.rate, func(t *testing.T) {
+			h := newTestServer(t, "-chaos", "-chaos-error-rate", tc.rate, "-chaos-seed", "1")
+			for i := 0; i < 20; i++ {
+				wantStatus(t, do(h, http.MethodGet, "/api/items", ""), tc.status)
+			}
+		})
+	}
+}
+
+func TestChaosErrorRateNeedsChaos(t *testing.T) {
+	h := newTestServer(t, "-chaos-error-rate", "1.0")
+	wantStatus(t, do(h, http.MethodGet, "/api/items", ""), http.StatusOK)
+}
+
+func TestChaosSeedIsReproducible(t *testing.T) {
+	run := func() []int {
+		h := newTestServer(t, "-chaos", "-chaos-error-rate", "0.5", "-chaos-seed", "42")
+		var codes []int
+		for i := 0; i < 20; i++ {
+			codes = append(codes, do(h, http.MethodGet, "/api/items", "").Code)
+		}
+		return codes
+	}
+	first, second := run(), run()
+	for i := range first {
+		if first[i] != second[i] {
+			t.Fatalf("runs with the same seed diverged: %v vs %v", first, second)
+		}
+	}
+}
@@ -0,0 +1,341 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type Config struct {
+	Chaos            bool     `json:"chaos"`
+	ChaosDelay       duration `json:"chaos_delay"`
+	ChaosErrorRate   float64  `json:"chaos_error_rate"`
+	ChaosSeed        int64    `json:"chaos_seed"`
+	ETagMode         string   `json:"etag_mode"`
+	MaxConnections   int      `json:"max_connections"`
+	AdminToken       string   `json:"admin_token"`
+	IdempotentDelete bool     `json:"idempotent_delete"`
+	ReadOnly         bool     `json:"read_only"`
+	ShutdownTimeout  duration `json:"shutdown_timeout"`
+	MaxURLLength     int      `json:"max_url_length"`
+	MaxQueryParams   int      `json:"max_query_params"`
+	MaxNameLength    int      `json:"max_name_length"`
+	RootBehavior     string   `json:"root_behavior"`
+	UniqueOn         []string `json:"unique_on"`
+	StatsdAddr       string   `json:"statsd_addr"`
+	AutoName         bool     `json:"auto_name"`
+	LockTTL          duration `json:"lock_ttl"`
+	ExportFlushBatch int      `json:"export_flush_batch"`
+	ImmutableFields  []string `json:"immutable_fields"`
+	JSONCharset      string   `json:"json_charset"`
+	DisabledMethods  []string `json:"disabled_methods"`
+	MaxBatchSize     int      `json:"max_batch_size"`
+	ReadinessTimeout duration `json:"readiness_timeout"`
+	ReadinessPolicy  string   `json:"readiness_policy"`
+	QuotaDefault     int      `json:"quota_default"`
+	// QuotaKeys maps API keys to their daily quota. The keys are
+	// credentials, so they are never exposed.
+	QuotaKeys     map[string]int `json:"-"`
+	QuotaTimezone string         `json:"quota_timezone"`
+	IDFormat      string         `json:"id_format"`
+	StrictQuery   bool           `json:"strict_query"`
+	PatchUpsert   bool           `json:"patch_upsert"`
+	// DeprecatedRoutes are path prefixes answered with deprecation headers.
+	DeprecatedRoutes       []string  `json:"deprecated_routes"`
+	Sunset                 time.Time `json:"sunset"`
+	ItemCacheControl       string    `json:"item_cache_control"`
+	CollectionCacheControl string    `json:"collection_cache_control"`
+	MaxDataSize            int       `json:"max_data_size"`
+	ExternalURL            string    `json:"external_url"`
+	Port                   int       `json:"port"`
+	MaxBodySize            int64     `json:"max_body_size"`
+}
+
+var config Config
+
+// duration is a time.Duration that marshals as a string such as "10s", so
+// /admin/config shows durations the way they are passed on the command line.
+type duration struct {
+	time.Duration
+}
+
+func (d duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
+}
+
+// redacted returns a copy of the config that is safe to expose, with secret
+// values masked.
+func (c Config) redacted() Config {
+	if c.AdminToken != "" {
+		c.AdminToken = redacted
+	}
+	return c
+}
+
+// flagValues holds the raw text of the flags that need parsing beyond what
+// package flag does. validateConfig parses them, so a bad value is reported
+// alongside every other problem instead of aborting flag parsing.
+type flagValues struct {
+	uniqueOn         string
+	immutableFields  string
+	disabledMethods  string
+	quotaKeys        string
+	deprecatedRoutes string
+	sunset           string
+	externalURL      string
+}
+
+func parseFlags() {
+	var raw flagValues
+	registerFlags(flag.CommandLine, &config, &raw)
+	flag.Parse()
+
+	problems := validateConfig(&config, raw)
+	fatal := 0
+	for _, p := range problems {
+		if p.fatal {
+			fatal++
+			log.Printf("Config error: %s", p.message)
+		} else {
+			log.Printf("Config warning: %s", p.message)
+		}
+	}
+	if fatal > 0 {
+		log.Fatalf("Refusing to start: %d configuration error(s)", fatal)
+	}
+
+	// validateConfig has vetted these already.
+	quotas.loc, _ = time.LoadLocation(config.QuotaTimezone)
+	if config.IDFormat == "ulid" {
+		idGenerator = newULIDs()
+	}
+}
+
+// registerFlags defines every command-line flag on fs, storing plain values
+// in c and the ones validateConfig parses in raw.
+func registerFlags(fs *flag.FlagSet, c *Config, raw *flagValues) {
+	fs.IntVar(&c.Port, "port", 8080, "TCP port to listen on")
+	fs.BoolVar(&c.Chaos, "chaos", false, "Enable chaos testing features (never use in production)")
+	fs.DurationVar(&c.ChaosDelay.Duration, "chaos-delay", 0, "Artificial delay added to each response when -chaos is set")
+	fs.Float64Var(&c.ChaosErrorRate, "chaos-error-rate", 0, "Fraction of requests (0.0-1.0) answered with 503 when -chaos is set")
+	fs.Int64Var(&c.ChaosSeed, "chaos-seed", 0, "Seed for chaos error injection (0 uses the current time)")
+	fs.StringVar(&c.ETagMode, "etag-mode", "strong", "ETag flavour for item responses: strong (hash of the body) or weak (item revision)")
+	fs.IntVar(&c.MaxConnections, "max-connections", 0, "Maximum number of concurrent connections (0 means unlimited)")
+	fs.StringVar(&c.AdminToken, "admin-token", os.Getenv("ADMIN_TOKEN"), "Bearer token for /admin endpoints (defaults to $ADMIN_TOKEN; admin endpoints are disabled when empty)")
+	fs.BoolVar(&c.IdempotentDelete, "idempotent-delete", false, "Answer every DELETE with 204, whether or not the item existed")
+	fs.BoolVar(&c.ReadOnly, "read-only", false, "Start with all item writes rejected (toggle at runtime via /admin/read-only)")
+	fs.DurationVar(&c.ShutdownTimeout.Duration, "shutdown-timeout", 10*time.Second, "How long to wait for in-flight requests on shutdown before forcing connections closed")
+	fs.IntVar(&c.MaxURLLength, "max-url-length", 8192, "Maximum request URL length in bytes before answering 414 (0 disables)")
+	fs.IntVar(&c.MaxQueryParams, "max-query-params", 100, "Maximum number of query parameters before answering 400 (0 disables)")
+	fs.IntVar(&c.MaxNameLength, "max-name-length", 256, "Maximum item name length in bytes (0 disables)")
+	fs.StringVar(&c.RootBehavior, "root-behavior", "health", "What / serves: health, 404, index, or redirect:<url>")
+	fs.StringVar(&raw.uniqueOn, "unique-on", "", "Comma-separated fields (name, value, tags) whose combination must be unique across items")
+	fs.StringVar(&c.StatsdAddr, "statsd-addr", "", "StatsD server (host:port) to send request metrics to over UDP (disabled when empty)")
+	fs.BoolVar(&c.AutoName, "auto-name", false, "Name items created without a name Item-<id> instead of rejecting them")
+	fs.DurationVar(&c.LockTTL.Duration, "lock-ttl", 30*time.Second, "How long an advisory item lock lasts before it expires")
+	fs.IntVar(&c.ExportFlushBatch, "export-flush-batch", 100, "Number of items written between flushes when streaming /api/items/export")
+	fs.StringVar(&raw.immutableFields, "immutable-fields", "id,created_at", "Comma-separated fields (id, name, value, tags, data, created_at) that updates may not change")
+	fs.StringVar(&c.JSONCharset, "json-charset", "utf-8", "charset parameter added to the JSON Content-Type (empty for bare application/json)")
+	fs.StringVar(&raw.disabledMethods, "disabled-methods", "", "Comma-separated HTTP methods (e.g. POST,PUT,PATCH,DELETE) rejected with 405 on item endpoints")
+	fs.IntVar(&c.MaxBatchSize, "max-batch-size", 10000, "Maximum number of entries in a bulk replace, sync or bulk get request (0 disables)")
+	fs.DurationVar(&c.ReadinessTimeout.Duration, "readiness-timeout", 2*time.Second, "Time limit for each /readyz dependency check")
+	fs.StringVar(&c.ReadinessPolicy, "readiness-policy", "strict", "strict: any failing check makes /readyz fail; degraded: only critical checks do")
+	fs.IntVar(&c.QuotaDefault, "quota-default", 0, "Daily request quota shared by all API keys not listed in -quota-keys (0 means unlimited)")
+	fs.StringVar(&raw.quotaKeys, "quota-keys", "", "Comma-separated key=N daily request quotas per X-API-Key value")
+	fs.StringVar(&c.QuotaTimezone, "quota-timezone", "UTC", "Time zone whose midnight resets the daily quotas (e.g. Europe/Berlin)")
+	fs.StringVar(&c.IDFormat, "id-format", "sequential", "IDs given to new items: sequential (1, 2, 3...) or ulid (sortable by creation time)")
+	fs.BoolVar(&c.StrictQuery, "strict-query", false, "Reject item requests with query parameters the endpoint doesn't recognise")
+	fs.BoolVar(&c.PatchUpsert, "patch-upsert", false, "Create the item when PATCH targets a missing ID instead of answering 404")
+	fs.StringVar(&raw.deprecatedRoutes, "deprecated-routes", "/items", "Comma-separated path prefixes whose responses carry Deprecation and Warning headers")
+	fs.StringVar(&raw.sunset, "sunset", "", "Date (YYYY-MM-DD or RFC3339) after which -deprecated-routes may stop working, sent as the Sunset header")
+	fs.StringVar(&c.ItemCacheControl, "item-cache-control", "max-age=60", "Cache-Control for successful single item reads (empty sends none)")
+	fs.StringVar(&c.CollectionCacheControl, "collection-cache-control", "no-store", "Cache-Control for item listings, exports and other collection reads (empty sends none)")
+	fs.IntVar(&c.MaxDataSize, "max-data-size", 64<<10, "Maximum size in bytes of an item's data field, after compaction (0 disables)")
+	fs.StringVar(&raw.externalURL, "external-url", "", "Public base URL (e.g. https://api.example.com) for absolute Location headers; relative paths are used when unset")
+	fs.Int64Var(&c.MaxBodySize, "max-body-size", 16<<20, "Maximum request body size in bytes before answering 413 (0 disables)")
+}
+
+type configProblem struct {
+	fatal   bool
+	message string
+}
+
+// validateConfig parses raw into c, checks c as a whole and returns every
+// problem found, so a misconfigured server reports them all at once instead
+// of one per restart. Warnings flag settings that are legal but probably not
+// what was meant.
+func validateConfig(c *Config, raw flagValues) []configProblem {
+	var problems []configProblem
+	fail := func(format string, args ...interface{}) {
+		problems = append(problems, configProblem{fatal: true, message: fmt.Sprintf(format, args...)})
+	}
+	warn := func(format string, args ...interface{}) {
+		problems = append(problems, configProblem{message: fmt.Sprintf(format, args...)})
+	}
+
+	c.UniqueOn = nil
+	for _, field := range splitList(raw.uniqueOn) {
+		if !uniqueFields[field] {
+			fail("Invalid -unique-on: unknown field %q", field)
+			continue
+		}
+		c.UniqueOn = append(c.UniqueOn, field)
+	}
+	c.ImmutableFields = nil
+	for _, field := range splitList(raw.immutableFields) {
+		if !immutableFieldNames[field] {
+			fail("Invalid -immutable-fields: unknown field %q", field)
+			continue
+		}
+		c.ImmutableFields = append(c.ImmutableFields, field)
+	}
+	c.DisabledMethods = nil
+	for _, method := range splitList(raw.disabledMethods) {
+		method = strings.ToUpper(method)
+		switch method {
+		case http.MethodGet, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete:
+			c.DisabledMethods = append(c.DisabledMethods, method)
+		default:
+			fail("Invalid -disabled-methods: unsupported method %q", method)
+		}
+	}
+	c.QuotaKeys = nil
+	for i, entry := range splitList(raw.quotaKeys) {
+		key, n, ok := strings.Cut(entry, "=")
+		limit, err := strconv.Atoi(n)
+		if !ok || key == "" || err != nil || limit < 0 {
+			// The entry holds a credential, so only its position is named.
+			fail("Invalid -quota-keys: entry %d is not key=N", i+1)
+			continue
+		}
+		if c.QuotaKeys == nil {
+			c.QuotaKeys = make(map[string]int)
+		}
+		c.QuotaKeys[key] = limit
+	}
+	c.DeprecatedRoutes = nil
+	for _, route := range splitList(raw.deprecatedRoutes) {
+		if !strings.HasPrefix(route, "/") {
+			fail("Invalid -deprecated-routes: route %q must start with /", route)
+			continue
+		}
+		c.DeprecatedRoutes = append(c.DeprecatedRoutes, route)
+	}
+	c.Sunset = time.Time{}
+	if raw.sunset != "" {
+		t, err := time.Parse(time.DateOnly, raw.sunset)
+		if err != nil {
+			t, err = time.Parse(time.RFC3339, raw.sunset)
+		}
+		if err != nil {
+			fail("Invalid -sunset %q: expected YYYY-MM-DD or an RFC3339 time", raw.sunset)
+		}
+		c.Sunset = t
+	}
+	c.ExternalURL = ""
+	if raw.externalURL != "" {
+		u, err := url.Parse(raw.externalURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || u.RawQuery != "" || u.Fragment != "" {
+			fail("Invalid -external-url %q: expected an absolute http(s) URL without query or fragment", raw.externalURL)
+		} else {
+			c.ExternalURL = raw.externalURL
+		}
+	}
+
+	if c.Port < 1 || c.Port > 65535 {
+		fail("Invalid -port %d: must be between 1 and 65535", c.Port)
+	}
+	if c.ETagMode != "strong" && c.ETagMode != "weak" {
+		fail("Invalid -etag-mode %q: must be strong or weak", c.ETagMode)
+	}
+	if c.ReadinessPolicy != "strict" && c.ReadinessPolicy != "degraded" {
+		fail("Invalid -readiness-policy %q: must be strict or degraded", c.ReadinessPolicy)
+	}
+	if _, err := time.LoadLocation(c.QuotaTimezone); err != nil {
+		fail("Invalid -quota-timezone %q: %v", c.QuotaTimezone, err)
+	}
+	if c.IDFormat != "sequential" && c.IDFormat != "ulid" {
+		fail("Invalid -id-format %q: must be sequential or ulid", c.IDFormat)
+	}
+	if c.ExportFlushBatch < 1 {
+		fail("Invalid -export-flush-batch %d: must be at least 1", c.ExportFlushBatch)
+	}
+	if !validRootBehavior(c.RootBehavior) {
+		fail("Invalid -root-behavior %q: must be health, 404, index, or redirect:<url>", c.RootBehavior)
+	}
+
+	for _, d := range []struct {
+		name string
+		d    time.Duration
+	}{
+		{"lock-ttl", c.LockTTL.Duration},
+		{"readiness-timeout", c.ReadinessTimeout.Duration},
+	} {
+		if d.d <= 0 {
+			fail("Invalid -%s %s: must be positive", d.name, d.d)
+		}
+	}
+	for _, n := range []struct {
+		name string
+		n    int
+	}{
+		{"max-connections", c.MaxConnections},
+		{"max-url-length", c.MaxURLLength},
+		{"max-query-params", c.MaxQueryParams},
+		{"max-name-length", c.MaxNameLength},
+		{"max-batch-size", c.MaxBatchSize},
+		{"max-data-size", c.MaxDataSize},
+		{"quota-default", c.QuotaDefault},
+	} {
+		if n.n < 0 {
+			fail("Invalid -%s %d: must not be negative", n.name, n.n)
+		}
+	}
+	if c.MaxBodySize < 0 {
+		fail("Invalid -max-body-size %d: must not be negative", c.MaxBodySize)
+	}
+	if c.ShutdownTimeout.Duration < 0 {
+		fail("Invalid -shutdown-timeout %s: must not be negative", c.ShutdownTimeout)
+	}
+	if c.ChaosDelay.Duration < 0 {
+		fail("Invalid -chaos-delay %s: must not be negative", c.ChaosDelay)
+	}
+	if c.ChaosErrorRate < 0 || c.ChaosErrorRate > 1 {
+		fail("Invalid -chaos-error-rate %g: must be between 0.0 and 1.0", c.ChaosErrorRate)
+	}
+	if !c.Chaos && (c.ChaosDelay.Duration > 0 || c.ChaosErrorRate > 0) {
+		warn("-chaos-delay and -chaos-error-rate have no effect without -chaos")
+	}
+	if c.StatsdAddr != "" {
+		if _, err := net.ResolveUDPAddr("udp", c.StatsdAddr); err != nil {
+			fail("Invalid -statsd-addr %q: %v", c.StatsdAddr, err)
+		}
+	}
+	if len(c.QuotaKeys) > 0 && c.QuotaDefault == 0 {
+		warn("Keys not listed in -quota-keys have no quota; set -quota-default to limit them too")
+	}
+	if c.ReadOnly && c.PatchUpsert {
+		warn("-patch-upsert has no effect while -read-only rejects writes")
+	}
+	return problems
+}
+
+// splitList splits a comma-separated flag value, trimming entries and
+// dropping empty ones.
+func splitList(v string) []string {
+	var entries []string
+	for _, entry := range strings.Split(v, ",") {
+		if entry = strings.TrimSpace(entry); entry != "" {
+			entries = append(entries, entry)
+		}
+	}
+	return entries
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+// configProblems parses args as command-line flags and returns what
+// validateConfig reports about them.
+func configProblems(t *testing.T, args ...string) []configProblem {
+	t.Helper()
+	var c Config
+	var raw flagValues
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	registerFlags(fs, &c, &raw)
+	if err := fs.Parse(args); err != nil {
+		t.Fatalf("parsing %q: %v", args, err)
+	}
+	return validateConfig(&c, raw)
+}
+
+func TestExternalURLValidation(t *testing.T) {
+	for _, value := range []string{"api.example.com", "ftp://api.example.com", "https://api.example.com/?x=1", "https://api.example.com/#top"} {
+		if problems := configProblems(t, "-external-url", value); len(problems) == 0 {
+			t.Errorf("-external-url %q accepted", value)
+		}
+	}
+	if problems := configProblems(t, "-external-url", "http://localhost:8080/base"); len(problems) != 0 {
+		t.Errorf("-external-url with a path: %v", problems)
+	}
+}
+
+func TestDefaultConfigIsClean(t *testing.T) {
+	if problems := configProblems(t); len(problems) != 0 {
+		t.Errorf("default config reports %v", problems)
+	}
+}
+
+// badArgs has several fatal problems and one warning, spread across flags
+// parsed by validateConfig and flags checked after parsing.
+var badArgs = []string{
+	"-port", "70000",
+	"-etag-mode", "medium",
+	"-unique-on", "name,colour",
+	"-quota-keys", "abc=1,secret",
+	"-quota-default", "5",
+	"-lock-ttl", "0s",
+	"-chaos-delay", "1s",
+}
+
+var badArgsReport = []string{
+	"Invalid -port 70000",
+	`Invalid -etag-mode "medium"`,
+	`Invalid -unique-on: unknown field "colour"`,
+	"Invalid -quota-keys: entry 2 is not key=N",
+	"Invalid -lock-ttl 0s",
+	"-chaos-delay and -chaos-error-rate have no effect without -chaos",
+}
+
+func TestValidateConfigReportsEveryProblem(t *testing.T) {
+	problems := configProblems(t, badArgs...)
+	if len(problems) != len(badArgsReport) {
+		t.Errorf("got %d problems, want %d: %v", len(problems), len(badArgsReport), problems)
+	}
+	fatal := 0
+	for _, p := range problems {
+		if p.fatal {
+			fatal++
+		}
+	}
+	if fatal != len(badArgsReport)-1 {
+		t.Errorf("got %d fatal problems, want %d", fatal, len(badArgsReport)-1)
+	}
+	for _, want := range badArgsReport {
+		found := false
+		for _, p := range problems {
+			found = found || strings.HasPrefix(p.message, want)
+		}
+		if !found {
+			t.Errorf("no problem reported as %q", want)
+		}
+	}
+	for _, p := range problems {
+		if strings.Contains(p.message, "secret") {
+			t.Errorf("problem %q leaks a quota key", p.message)
+		}
+	}
+}
+
+// TestStartupAbortsOnBadConfig runs parseFlags in a child process, since it
+// exits the process when the config is fatally wrong.
+func TestStartupAbortsOnBadConfig(t *testing.T) {
+	if os.Getenv("CODELABS_PARSE_FLAGS") == "1" {
+		os.Args = append([]string{os.Args[0]}, badArgs...)
+		parseFlags()
+		os.Exit(0)
+	}
+
+	cmd := exec.Command(os.Args[0], "-test.run=^TestStartupAbortsOnBadConfig$")
+	cmd.Env = append(os.Environ(), "CODELABS_PARSE_FLAGS=1")
+	out, err := cmd.CombinedOutput()
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) || exitErr.ExitCode() == 0 {
+		t.Fatalf("parseFlags with a bad config returned %v, want a non-zero exit; output:\n%s", err, out)
+	}
+	for _, want := range badArgsReport {
+		if !strings.Contains(string(out), want) {
+			t.Errorf("output does not report %q:\n%s", want, out)
+		}
+	}
+	if !strings.Contains(string(out), "Refusing to start: 5 configuration error(s)") {
+		t.Errorf("output does not refuse to start:\n%s", out)
+	}
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestItemDataRoundTrip(t *testing.T) {
+	h := newTestServer(t)
+	blob := `{"owner":{"team":"infra","ids":[1,2.5,-3e2]},"flags":[true,false,null],"note":"café"}`
+	w := do(h, http.MethodPost, "/api/items", `{"name":"With data","data":`+blob+`}`)
+	wantStatus(t, w, http.StatusCreated)
+	var created Item
+	decode(t, w, &created)
+
+	w = do(h, http.MethodGet, "/api/items/"+created.ID, "")
+	wantStatus(t, w, http.StatusOK)
+	if !strings.Contains(w.Body.String(), `"data":`+blob) {
+		t.Errorf("body %s does not carry data %s untouched", w.Body.String(), blob)
+	}
+}
+
+func TestItemDataRejectsInvalidJSON(t *testing.T) {
+	h := newTestServer(t)
+	for _, body := range []string{
+		`{"name":"Bad","data":{"a":}}`,
+		`{"name":"Bad","data":[1,2}`,
+		`{"name":"Bad","data":undefined}`,
+	} {
+		wantStatus(t, do(h, http.MethodPost, "/api/items", body), http.StatusBadRequest)
+	}
+}
+
+func TestItemDataNullIsNone(t *testing.T) {
+	h := newTestServer(t)
+	w := do(h, http.MethodPost, "/api/items", `{"name":"Null data","data":null}`)
+	wantStatus(t, w, http.StatusCreated)
+	if strings.Contains(w.Body.String(), `"data"`) {
+		t.Errorf("body %s, want no data field", w.Body.String())
+	}
+}
+
+// TestItemDataSizeAfterCompaction sends data whose compact form is exactly
+// -max-data-size bytes but whose spaced-out form is larger, on every path
+// that writes items.
+func TestItemDataSizeAfterCompaction(t *testing.T) {
+	const compact = `{"a":[1,2,3],"b":"x"}`
+	const spaced = `{ "a": [1, 2, 3], "b": "x" }`
+	const tooBig = `{"a":[1,2,3],"b":"xy"}`
+	h := newTestServer(t, "-max-data-size", fmt.Sprint(len(compact)))
+
+	for _, tc := range []struct {
+		method, target, body string
+		status               int
+	}{
+		{http.MethodPost, "/api/items", `{"name":"A","data":%s}`, http.StatusCreated},
+		{http.MethodPut, "/api/items/1", `{"name":"A","data":%s}`, http.StatusOK},
+		{http.MethodPatch, "/api/items/2", `{"data":%s}`, http.StatusOK},
+		{http.MethodPut, "/api/items?confirm=true", `[{"id":"1","name":"A","data":%s}]`, http.StatusOK},
+	} {
+		if w := do(h, tc.method, tc.target, fmt.Sprintf(tc.body, spaced)); w.Code != tc.status {
+			t.Errorf("%s %s with spaced data: status = %d, want %d; body %s", tc.method, tc.target, w.Code, tc.status, w.Body.String())
+		}
+		if w := do(h, tc.method, tc.target, fmt.Sprintf(tc.body, tooBig)); w.Code != http.StatusBadRequest {
+			t.Errorf("%s %s with oversized data: status = %d, want 400", tc.method, tc.target, w.Code)
+		}
+	}
+
+	w := do(h, http.MethodGet, "/api/items/1", "")
+	if !strings.Contains(w.Body.String(), `"data":`+compact) {
+		t.Errorf("body %s, want data stored compacted", w.Body.String())
+	}
+
+	base := currentRevision(t, h)
+	syncItems(t, h, fmt.Sprintf(`{"base_revision":%d,"changes":[{"op":"put","item":{"id":"1","name":"A","data":%s}}]}`, base, spaced))
+	w = do(h, http.MethodPost, "/api/items/sync", fmt.Sprintf(`{"base_revision":%d,"changes":[{"op":"put","item":{"id":"1","name":"A","data":%s}}]}`, base+1, tooBig))
+	wantStatus(t, w, http.StatusBadRequest)
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"time"
+)
+
+// isDeprecated reports whether path is one of -deprecated-routes or below
+// one of them.
+func isDeprecated(path string) bool {
+	for _, route := range config.DeprecatedRoutes {
+		if path == route || strings.HasPrefix(path, strings.TrimSuffix(route, "/")+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// deprecationMiddleware marks responses from -deprecated-routes with
+// Deprecation, Sunset (RFC 8594) and Warning headers. The request itself is
+// handled exactly as before.
+func deprecationMiddleware(next http.Handler) http.Handler {
+	if len(config.DeprecatedRoutes) == 0 {
+		return next
+	}
+	warning := `299 - "Deprecated API"`
+	if !config.Sunset.IsZero() {
+		warning = `299 - "Deprecated API, may be removed after ` + config.Sunset.Format(time.DateOnly) + `"`
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if isDeprecated(r.URL.Path) {
+			h := w.Header()
+			h.Set("Deprecation", "true")
+			if !config.Sunset.IsZero() {
+				h.Set("Sunset", config.Sunset.UTC().Format(http.TimeFormat))
+			}
+			h.Set("Warning", warning)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
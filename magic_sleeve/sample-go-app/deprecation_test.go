@@ -0,0 +1,47 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestDeprecationHeaders(t *testing.T) {
+	h := newTestServer(t, "-sunset", "2030-06-30")
+	for _, target := range []string{"/items", "/items/1"} {
+		w := do(h, http.MethodGet, target, "")
+		wantStatus(t, w, http.StatusOK)
+		if got := w.Header().Get("Deprecation"); got != "true" {
+			t.Errorf("%s: Deprecation = %q, want true", target, got)
+		}
+		if got := w.Header().Get("Sunset"); got != "Sun, 30 Jun 2030 00:00:00 GMT" {
+			t.Errorf("%s: Sunset = %q", target, got)
+		}
+		if got := w.Header().Get("Warning"); got != `299 - "Deprecated API, may be removed after 2030-06-30"` {
+			t.Errorf("%s: Warning = %q", target, got)
+		}
+	}
+
+	for _, target := range []string{"/api/items", "/api/items/1"} {
+		w := do(h, http.MethodGet, target, "")
+		for _, header := range []string{"Deprecation", "Sunset", "Warning"} {
+			if got := w.Header().Get(header); got != "" {
+				t.Errorf("%s: versioned route has %s: %s", target, header, got)
+			}
+		}
+	}
+}
+
+func TestDeprecatedRoutesConfigurable(t *testing.T) {
+	h := newTestServer(t, "-deprecated-routes", "")
+	if got := do(h, http.MethodGet, "/items", "").Header().Get("Deprecation"); got != "" {
+		t.Errorf("Deprecation = %q with no deprecated routes", got)
+	}
+
+	h = newTestServer(t, "-deprecated-routes", "/api/items/export")
+	if got := do(h, http.MethodGet, "/api/items/export", "").Header().Get("Deprecation"); got != "true" {
+		t.Errorf("Deprecation = %q on a configured route", got)
+	}
+	if got := do(h, http.MethodGet, "/api/items/exports", "").Header().Get("Deprecation"); got != "" {
+		t.Errorf("Deprecation = %q on a route that only shares a prefix", got)
+	}
+}
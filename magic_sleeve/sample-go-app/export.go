@@ -0,0 +1,96 @@
+package main
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"time"
+)
+
+// instanceID tells this process's store revisions apart from those of
+// earlier runs, which restart from the same numbers with different
+// timestamps.
+var instanceID = func() string {
+	b := make([]byte, 4)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}()
+
+// exportETag is the strong ETag of the export at revision. The export
+// serializes the same items in the same order for a given revision, so the
+// ETag is known before the body is written and an interrupted download
+// still has a validator to resume with.
+func exportETag(revision uint64) string {
+	return fmt.Sprintf(`"%s-%d"`, instanceID, revision)
+}
+
+// exportHandler streams every item as newline-delimited JSON. Output is
+// flushed every -export-flush-batch items so a large export never piles up
+// in server buffers, and the export stops as soon as the client goes away.
+//
+// A SHA-256 of the body is computed while streaming and sent as a
+// Content-Digest trailer (RFC 9530), so clients that read trailers can check
+// the body arrived intact. An aborted export gets no digest.
+func exportHandler(w http.ResponseWriter, r *http.Request) {
+	if !allowQuery(w, r) {
+		return
+	}
+	items, revision, err := store.list(r.Context())
+	if err != nil {
+		writeStoreError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.Header().Set("ETag", exportETag(revision))
+	w.Header().Set("Accept-Ranges", "bytes")
+	if r.Header.Get("Range") != "" {
+		serveExportRange(w, r, items)
+		return
+	}
+	w.Header().Set("Trailer", "Content-Digest")
+	rc := http.NewResponseController(w)
+	hash := sha256.New()
+	enc := json.NewEncoder(io.MultiWriter(w, hash))
+	for i, item := range items {
+		if err := r.Context().Err(); err != nil {
+			log.Printf("Export aborted after %d of %d items: %v", i, len(items), err)
+			return
+		}
+		if err := enc.Encode(item); err != nil {
+			log.Printf("Export aborted after %d of %d items: %v", i, len(items), err)
+			return
+		}
+		if (i+1)%config.ExportFlushBatch == 0 {
+			if err := rc.Flush(); err != nil {
+				log.Printf("Export aborted after %d of %d items: %v", i+1, len(items), err)
+				return
+			}
+		}
+	}
+	w.Header().Set("Content-Digest", "sha-256=:"+base64.StdEncoding.EncodeToString(hash.Sum(nil))+":")
+}
+
+// serveExportRange answers a Range request, typically a client resuming an
+// interrupted export. Byte offsets are only meaningful against a fixed body,
+// so the export is rendered in full and served from that snapshot. With
+// If-Range set to the ETag of the earlier attempt, ServeContent falls back
+// to the full export if the items have changed in between.
+func serveExportRange(w http.ResponseWriter, r *http.Request, items []Item) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for _, item := range items {
+		if err := enc.Encode(item); err != nil {
+			writeError(w, http.StatusInternalServerError, "Failed to encode export")
+			return
+		}
+	}
+	http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(buf.Bytes()))
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// seedItems replaces the store with n items named after their IDs.
+func seedItems(t *testing.T, h http.Handler, n int) {
+	t.Helper()
+	items := make([]Item, n)
+	for i := range items {
+		items[i] = Item{ID: fmt.Sprint(i + 1), Name: fmt.Sprint("Item ", i+1), Value: i}
+	}
+	body, _ := json.Marshal(items)
+	wantStatus(t, do(h, http.MethodPut, "/api/items?confirm=true", string(body)), http.StatusOK)
+}
+
+// slowClient is a response writer that takes a while to accept each flush,
+// like a client on a slow link. It records how much of the body had been
+// written at every flush and can cancel the request after a number of them.
+type slowClient struct {
+	*httptest.ResponseRecorder
+	flushedAt   []int
+	cancelAfter int
+	cancel      context.CancelFunc
+}
+
+func (c *slowClient) Flush() {
+	c.ResponseRecorder.Flush()
+	c.flushedAt = append(c.flushedAt, bytes.Count(c.Body.Bytes(), []byte("\n")))
+	time.Sleep(time.Millisecond)
+	if len(c.flushedAt) == c.cancelAfter {
+		c.cancel()
+	}
+}
+
+func TestExportFlushesInBatches(t *testing.T) {
+	h := newTestServer(t, "-export-flush-batch", "10")
+	seedItems(t, h, 100)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	client := &slowClient{ResponseRecorder: httptest.NewRecorder(), cancel: cancel}
+
+	h.ServeHTTP(client, newRequest(http.MethodGet, "/api/items/export", "").WithContext(ctx))
+	wantStatus(t, client.ResponseRecorder, http.StatusOK)
+	if len(client.flushedAt) != 10 {
+		t.Fatalf("flushed %d times, want 10", len(client.flushedAt))
+	}
+	for i, lines := range client.flushedAt {
+		if lines != (i+1)*10 {
+			t.Errorf("flush %d happened after %d lines, want %d", i+1, lines, (i+1)*10)
+		}
+	}
+}
+
+func TestExportStopsWhenClientGoesAway(t *testing.T) {
+	h := newTestServer(t, "-export-flush-batch", "10")
+	seedItems(t, h, 1000)
+	logs := captureLog(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	client := &slowClient{ResponseRecorder: httptest.NewRecorder(), cancelAfter: 3, cancel: cancel}
+
+	h.ServeHTTP(client, newRequest(http.MethodGet, "/api/items/export", "").WithContext(ctx))
+	if lines := bytes.Count(client.Body.Bytes(), []byte("\n")); lines != 30 {
+		t.Errorf("export wrote %d lines after the client went away at 30", lines)
+	}
+	if client.Header().Get("Content-Digest") != "" {
+		t.Error("an aborted export got a Content-Digest")
+	}
+	if !strings.Contains(logs.String(), "Export aborted after 30 of 1000 items") {
+		t.Errorf("abort was not logged:\n%s", logs.String())
+	}
+}
+
+func TestExportDigestTrailer(t *testing.T) {
+	srv := httptest.NewServer(newTestServer(t))
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/api/items/export")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if _, declared := resp.Trailer["Content-Digest"]; !declared {
+		t.Fatalf("Content-Digest not declared as a trailer; headers %v", resp.Header)
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lines := bytes.Count(body, []byte("\n")); lines != 3 {
+		t.Errorf("export has %d lines, want 3", lines)
+	}
+
+	sum := sha256.Sum256(body)
+	want := "sha-256=:" + base64.StdEncoding.EncodeToString(sum[:]) + ":"
+	if got := resp.Trailer.Get("Content-Digest"); got != want {
+		t.Errorf("Content-Digest trailer = %q, want %q", got, want)
+	}
+}
+
+func TestExportRange(t *testing.T) {
+	h := newTestServer(t)
+	full := do(h, http.MethodGet, "/api/items/export", "")
+	wantStatus(t, full, http.StatusOK)
+	etag := full.Header().Get("ETag")
+	if etag == "" || strings.HasPrefix(etag, "W/") || full.Header().Get("Accept-Ranges") != "bytes" {
+		t.Fatalf("full export sent ETag %q and Accept-Ranges %q, want a strong ETag and bytes",
+			etag, full.Header().Get("Accept-Ranges"))
+	}
+	body := full.Body.Bytes()
+
+	r := newRequest(http.MethodGet, "/api/items/export", "")
+	r.Header.Set("Range", "bytes=10-29")
+	r.Header.Set("If-Range", etag)
+	w := serve(h, r)
+	wantStatus(t, w, http.StatusPartialContent)
+	if got, want := w.Header().Get("Content-Range"), fmt.Sprintf("bytes 10-29/%d", len(body)); got != want {
+		t.Errorf("Content-Range = %q, want %q", got, want)
+	}
+	if !bytes.Equal(w.Body.Bytes(), body[10:30]) {
+		t.Errorf("partial body = %q, want %q", w.Body.Bytes(), body[10:30])
+	}
+
+	// Once the items change, resuming with the old ETag restarts the export.
+	wantStatus(t, do(h, http.MethodDelete, "/api/items/1", ""), http.StatusOK)
+	w = serve(h, r)
+	wantStatus(t, w, http.StatusOK)
+	if w.Header().Get("ETag") == etag {
+		t.Error("ETag did not change with the items")
+	}
+}
@@ -2,3 +2,4 @@ module simple-go-app
 
 go 1.21
 
+require golang.org/x/net v0.21.0
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// jsonContentType is the Content-Type of every JSON response, success or
+// error, with the -json-charset parameter when one is configured.
+func jsonContentType() string {
+	if config.JSONCharset == "" {
+		return "application/json"
+	}
+	return "application/json; charset=" + config.JSONCharset
+}
+
+// writeJSON writes v as the JSON response body. encoding/json emits struct
+// fields in declaration order and map keys sorted, so a given value always
+// serializes to the same bytes.
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", jsonContentType())
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("Failed to write response: %v", err)
+	}
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]string{"error": message})
+}
+
+var errInvalidUTF8 = errors.New("request body is not valid UTF-8")
+
+// decodeJSON decodes the request body into v, returning io.EOF for an empty
+// body. Invalid UTF-8 is rejected up front because encoding/json would
+// otherwise silently replace the bad bytes with U+FFFD and store text the
+// client never sent.
+func decodeJSON(r *http.Request, v interface{}) error {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		return err
+	}
+	if len(bytes.TrimSpace(body)) == 0 {
+		return io.EOF
+	}
+	if !utf8.Valid(body) {
+		return errInvalidUTF8
+	}
+	return json.Unmarshal(body, v)
+}
+
+func writeDecodeError(w http.ResponseWriter, err error, message string) {
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		writeError(w, http.StatusRequestEntityTooLarge, fmt.Sprintf("Request body exceeds %d bytes", tooLarge.Limit))
+		return
+	}
+	switch {
+	case errors.Is(err, errInvalidUTF8):
+		message = "Request body is not valid UTF-8"
+	case errors.Is(err, errBatchTooLarge):
+		message = fmt.Sprintf("Batch exceeds the maximum of %d entries", config.MaxBatchSize)
+	}
+	writeError(w, http.StatusBadRequest, message)
+}
+
+// writeStoreError maps an error returned by a Store method to a response.
+func writeStoreError(w http.ResponseWriter, err error) {
+	var uniqueErr *uniqueError
+	var validationErr *validationError
+	var immutableErr *immutableError
+	switch {
+	case errors.Is(err, errNotFound):
+		writeError(w, http.StatusNotFound, "Item not found")
+	case errors.As(err, &validationErr):
+		writeError(w, http.StatusBadRequest, err.Error())
+	case errors.Is(err, errIDTaken), errors.As(err, &uniqueErr), errors.As(err, &immutableErr):
+		writeError(w, http.StatusConflict, err.Error())
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		writeError(w, http.StatusServiceUnavailable, "Storage operation did not complete: "+err.Error())
+	default:
+		writeError(w, http.StatusInternalServerError, err.Error())
+	}
+}
+
+// itemLocation returns the URL of the item with id for Location headers. It
+// is relative unless -external-url is set: the request's Host can't be
+// trusted to build an absolute URL, and HTTP/1.0 clients may not send one
+// at all.
+func itemLocation(id string) string {
+	path := (&url.URL{Path: "/api/items/" + id}).EscapedPath()
+	return strings.TrimSuffix(config.ExternalURL, "/") + path
+}
+
+// writeItem writes a single item with ETag and Last-Modified validators and
+// answers 304 when the request's conditional headers show the client's copy
+// is current.
+func writeItem(w http.ResponseWriter, r *http.Request, item Item) {
+	body, err := json.Marshal(item)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to encode item")
+		return
+	}
+	body = append(body, '\n')
+
+	etag := itemETag(item, body)
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Last-Modified", item.UpdatedAt.Format(http.TimeFormat))
+
+	// If-None-Match takes precedence (RFC 7232, section 6): when present it
+	// decides alone, even if If-Modified-Since would say otherwise.
+	var notModified bool
+	if inm := r.Header.Get("If-None-Match"); inm != "" {
+		notModified = etagMatches(inm, etag)
+	} else {
+		notModified = unmodifiedSince(r.Header.Get("If-Modified-Since"), item.UpdatedAt)
+	}
+	if notModified {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	w.Header().Set("Content-Type", jsonContentType())
+	w.Write(body)
+}
+
+// itemETag returns a weak ETag derived from the item's store revision or a
+// strong ETag hashing the exact response bytes, depending on -etag-mode.
+func itemETag(item Item, body []byte) string {
+	if config.ETagMode == "weak" {
+		return fmt.Sprintf(`W/"%d"`, item.revision)
+	}
+	sum := sha256.Sum256(body)
+	return `"` + hex.EncodeToString(sum[:16]) + `"`
+}
+
+// etagMatches reports whether an If-None-Match header matches etag using the
+// weak comparison RFC 7232 requires for that header.
+func etagMatches(header, etag string) bool {
+	if header == "" {
+		return false
+	}
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+	}
+	return false
+}
+
+// unmodifiedSince reports whether modified is no later than the
+// If-Modified-Since header. An unparseable header is treated as absent.
+func unmodifiedSince(header string, modified time.Time) bool {
+	if header == "" {
+		return false
+	}
+	since, err := http.ParseTime(header)
+	if err != nil {
+		return false
+	}
+	// HTTP dates have one-second resolution.
+	return !modified.Truncate(time.Second).After(since)
+}
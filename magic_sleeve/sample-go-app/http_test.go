@@ -0,0 +1,140 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWeakETag(t *testing.T) {
+	h := newTestServer(t, "-etag-mode", "weak")
+	w := do(h, http.MethodGet, "/api/items/1", "")
+	wantStatus(t, w, http.StatusOK)
+	etag := w.Header().Get("ETag")
+	if !strings.HasPrefix(etag, `W/"`) {
+		t.Fatalf("ETag = %q, want a W/ prefix", etag)
+	}
+
+	r := newRequest(http.MethodGet, "/api/items/1", "")
+	r.Header.Set("If-None-Match", etag)
+	wantStatus(t, serve(h, r), http.StatusNotModified)
+
+	wantStatus(t, do(h, http.MethodPut, "/api/items/1", `{"name":"Renamed","value":1}`), http.StatusOK)
+	r = newRequest(http.MethodGet, "/api/items/1", "")
+	r.Header.Set("If-None-Match", etag)
+	w = serve(h, r)
+	wantStatus(t, w, http.StatusOK)
+	if w.Header().Get("ETag") == etag {
+		t.Errorf("ETag %q did not change after an update", etag)
+	}
+}
+
+func TestStrongETagHashesBody(t *testing.T) {
+	h := newTestServer(t)
+	w := do(h, http.MethodGet, "/api/items/1", "")
+	wantStatus(t, w, http.StatusOK)
+	sum := sha256.Sum256(w.Body.Bytes())
+	want := `"` + hex.EncodeToString(sum[:16]) + `"`
+	if etag := w.Header().Get("ETag"); etag != want {
+		t.Fatalf("ETag = %q, want %q", etag, want)
+	}
+
+	r := newRequest(http.MethodGet, "/api/items/1", "")
+	r.Header.Set("If-None-Match", `"other", `+want)
+	wantStatus(t, serve(h, r), http.StatusNotModified)
+	r = newRequest(http.MethodGet, "/api/items/1", "")
+	r.Header.Set("If-None-Match", `"other"`)
+	wantStatus(t, serve(h, r), http.StatusOK)
+}
+
+func TestIfModifiedSince(t *testing.T) {
+	h := newTestServer(t)
+	w := do(h, http.MethodGet, "/api/items/1", "")
+	wantStatus(t, w, http.StatusOK)
+	lastModified := w.Header().Get("Last-Modified")
+	if _, err := http.ParseTime(lastModified); err != nil {
+		t.Fatalf("Last-Modified = %q: %v", lastModified, err)
+	}
+
+	conditional := func(since string) *httptest.ResponseRecorder {
+		r := newRequest(http.MethodGet, "/api/items/1", "")
+		r.Header.Set("If-Modified-Since", since)
+		return serve(h, r)
+	}
+	w = conditional(lastModified)
+	wantStatus(t, w, http.StatusNotModified)
+	if w.Body.Len() != 0 {
+		t.Errorf("304 carried a body: %q", w.Body.String())
+	}
+	wantStatus(t, conditional("not a date"), http.StatusOK)
+
+	// HTTP dates have one-second resolution, so the update has to land in a
+	// later second to be visible.
+	time.Sleep(time.Until(time.Now().Truncate(time.Second).Add(time.Second)))
+	wantStatus(t, do(h, http.MethodPut, "/api/items/1", `{"name":"Changed"}`), http.StatusOK)
+	wantStatus(t, conditional(lastModified), http.StatusOK)
+}
+
+func TestMapResponsesAreDeterministic(t *testing.T) {
+	h := newTestServer(t)
+	first := do(h, http.MethodGet, "/api/items?as=map", "").Body.String()
+	for i := 0; i < 10; i++ {
+		if again := do(h, http.MethodGet, "/api/items?as=map", "").Body.String(); again != first {
+			t.Fatalf("map listing differs between requests:\n%s\n%s", first, again)
+		}
+	}
+
+	w := httptest.NewRecorder()
+	writeJSON(w, http.StatusOK, map[string]int{"b": 2, "c": 3, "a": 1})
+	if got := w.Body.String(); got != `{"a":1,"b":2,"c":3}`+"\n" {
+		t.Errorf("writeJSON wrote %q, want keys in sorted order", got)
+	}
+}
+
+func TestJSONContentType(t *testing.T) {
+	for _, tc := range []struct {
+		args []string
+		want string
+	}{
+		{nil, "application/json; charset=utf-8"},
+		{[]string{"-json-charset", ""}, "application/json"},
+		{[]string{"-json-charset", "UTF-8"}, "application/json; charset=UTF-8"},
+	} {
+		h := newTestServer(t, tc.args...)
+		for _, target := range []string{"/api/items", "/api/items/1", "/api/items/99", "/api/items?as=bogus"} {
+			if got := do(h, http.MethodGet, target, "").Header().Get("Content-Type"); got != tc.want {
+				t.Errorf("%v %s: Content-Type %q, want %q", tc.args, target, got, tc.want)
+			}
+		}
+	}
+}
+
+func TestIfNoneMatchTakesPrecedence(t *testing.T) {
+	h := newTestServer(t)
+	w := do(h, http.MethodGet, "/api/items/1", "")
+	etag, lastModified := w.Header().Get("ETag"), w.Header().Get("Last-Modified")
+	longAgo := time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC).Format(http.TimeFormat)
+
+	for _, tc := range []struct {
+		name, inm, ims string
+		status         int
+	}{
+		// The date says unchanged, the stale ETag says changed.
+		{"stale etag, current date", `"stale"`, lastModified, http.StatusOK},
+		// The date says changed, the ETag says unchanged.
+		{"current etag, old date", etag, longAgo, http.StatusNotModified},
+		{"current etag, current date", etag, lastModified, http.StatusNotModified},
+		{"stale etag, old date", `"stale"`, longAgo, http.StatusOK},
+	} {
+		r := newRequest(http.MethodGet, "/api/items/1", "")
+		r.Header.Set("If-None-Match", tc.inm)
+		r.Header.Set("If-Modified-Since", tc.ims)
+		if w := serve(h, r); w.Code != tc.status {
+			t.Errorf("%s: status %d, want %d", tc.name, w.Code, tc.status)
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// IDGenerator assigns IDs to items created without one. count is the
+// number of stored items and taken reports whether an ID is in use; both
+// are only valid while the store's write lock is held.
+type IDGenerator interface {
+	newID(count int, taken func(id string) bool) string
+}
+
+// idGenerator is chosen by -id-format.
+var idGenerator IDGenerator = sequentialIDs{}
+
+// sequentialIDs hands out the lowest number above the item count that is
+// not already taken.
+type sequentialIDs struct{}
+
+func (sequentialIDs) newID(count int, taken func(string) bool) string {
+	for n := count + 1; ; n++ {
+		if id := strconv.Itoa(n); !taken(id) {
+			return id
+		}
+	}
+}
+
+// crockford is the ULID alphabet. Its characters are in ASCII order, so
+// ULIDs sort lexically in the order they were generated.
+const crockford = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+// ulidIDs generates ULIDs: a 48-bit millisecond timestamp followed by 80
+// random bits. IDs made within the same millisecond reuse the previous
+// random part plus one, so they stay strictly increasing.
+type ulidIDs struct {
+	mu   sync.Mutex
+	ms   uint64
+	hi   uint16 // top 16 random bits
+	lo   uint64 // bottom 64 random bits
+	now  func() time.Time
+	rand func([]byte) (int, error)
+}
+
+func newULIDs() *ulidIDs {
+	return &ulidIDs{now: time.Now, rand: rand.Read}
+}
+
+func (g *ulidIDs) newID(_ int, taken func(string) bool) string {
+	for {
+		if id := g.next(); !taken(id) {
+			return id
+		}
+	}
+}
+
+func (g *ulidIDs) next() string {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	ms := uint64(g.now().UnixMilli())
+	if ms > g.ms {
+		var entropy [10]byte
+		if _, err := g.rand(entropy[:]); err != nil {
+			panic("ulid: reading random bytes: " + err.Error())
+		}
+		g.ms = ms
+		g.hi = binary.BigEndian.Uint16(entropy[:2])
+		g.lo = binary.BigEndian.Uint64(entropy[2:])
+	} else {
+		// Same millisecond, or the clock went backwards: keep counting from
+		// the last ID. If the random part overflows, borrow the next
+		// millisecond.
+		g.lo++
+		if g.lo == 0 {
+			g.hi++
+			if g.hi == 0 {
+				g.ms++
+			}
+		}
+	}
+
+	var b [16]byte
+	binary.BigEndian.PutUint64(b[:8], g.ms<<16|uint64(g.hi))
+	binary.BigEndian.PutUint64(b[8:], g.lo)
+	return encodeULID(b)
+}
+
+// encodeULID writes the 128 bits of b as 26 Crockford base32 characters,
+// most significant first. The first character only carries 3 bits.
+func encodeULID(b [16]byte) string {
+	hi := binary.BigEndian.Uint64(b[:8])
+	lo := binary.BigEndian.Uint64(b[8:])
+	var out [26]byte
+	for i := 25; i >= 0; i-- {
+		out[i] = crockford[lo&31]
+		lo = lo>>5 | hi<<59
+		hi >>= 5
+	}
+	return string(out[:])
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func noneTaken(string) bool { return false }
+
+// ulidTime decodes the millisecond timestamp in the first 10 characters.
+func ulidTime(id string) time.Time {
+	var ms int64
+	for _, c := range id[:10] {
+		ms = ms<<5 | int64(strings.IndexRune(crockford, c))
+	}
+	return time.UnixMilli(ms)
+}
+
+func TestULIDsAreValid(t *testing.T) {
+	g := newULIDs()
+	before := time.Now().Truncate(time.Millisecond)
+	id := g.newID(0, noneTaken)
+	if len(id) != 26 || id[0] > '7' {
+		t.Fatalf("%q is not a 26-character ULID", id)
+	}
+	for _, c := range id {
+		if !strings.ContainsRune(crockford, c) {
+			t.Fatalf("%q contains %q, which is not Crockford base32", id, c)
+		}
+	}
+	if ts := ulidTime(id); ts.Before(before) || ts.After(time.Now()) {
+		t.Errorf("%q encodes %s, want the time it was made", id, ts)
+	}
+}
+
+func TestULIDsSortInCreationOrder(t *testing.T) {
+	clock := time.UnixMilli(1700000000000)
+	g := newULIDs()
+	g.now = func() time.Time { return clock }
+
+	prev := ""
+	for i := 0; i < 3000; i++ {
+		// Several IDs per millisecond, and the clock stepping back once.
+		switch {
+		case i%100 == 99:
+			clock = clock.Add(time.Millisecond)
+		case i == 1500:
+			clock = clock.Add(-time.Second)
+		}
+		id := g.newID(0, noneTaken)
+		if id <= prev {
+			t.Fatalf("ID %d %q does not sort after %q", i, id, prev)
+		}
+		prev = id
+	}
+}
+
+func TestULIDsUniqueUnderConcurrentCreates(t *testing.T) {
+	h := newTestServer(t, "-id-format", "ulid")
+	var mu sync.Mutex
+	seen := make(map[string]bool)
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				w := do(h, http.MethodPost, "/api/items", `{"name":"concurrent"}`)
+				if w.Code != http.StatusCreated {
+					t.Errorf("status %d: %s", w.Code, w.Body.String())
+					return
+				}
+				// decode would call t.Fatal, which must not run off the
+				// test goroutine.
+				var item Item
+				if err := json.Unmarshal(w.Body.Bytes(), &item); err != nil {
+					t.Error(err)
+					return
+				}
+				mu.Lock()
+				if seen[item.ID] {
+					t.Errorf("ID %s handed out twice", item.ID)
+				}
+				seen[item.ID] = true
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	if len(seen) != 400 {
+		t.Errorf("got %d distinct IDs, want 400", len(seen))
+	}
+}
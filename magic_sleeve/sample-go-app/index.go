@@ -0,0 +1,78 @@
+package main
+
+import (
+	_ "embed"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+//go:embed index.html
+var indexPage []byte
+
+// rootHandler serves "/" according to -root-behavior. In the default health
+// mode browsers get the index page while everything else, including clients
+// that accept any type, keeps getting the health JSON that "/" has always
+// returned.
+func rootHandler(w http.ResponseWriter, r *http.Request) {
+	if config.RootBehavior == "health" {
+		if r.URL.Path != "/" || !prefersHTML(r.Header.Get("Accept")) {
+			healthHandler(w, r)
+			return
+		}
+		writeIndex(w)
+		return
+	}
+
+	if r.URL.Path != "/" {
+		writeError(w, http.StatusNotFound, "Not found")
+		return
+	}
+	if target, ok := strings.CutPrefix(config.RootBehavior, "redirect:"); ok {
+		http.Redirect(w, r, target, http.StatusFound)
+		return
+	}
+	if config.RootBehavior == "index" {
+		writeIndex(w)
+		return
+	}
+	writeError(w, http.StatusNotFound, "Not found")
+}
+
+func writeIndex(w http.ResponseWriter) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write(indexPage)
+}
+
+func validRootBehavior(behavior string) bool {
+	switch behavior {
+	case "health", "404", "index":
+		return true
+	}
+	target, ok := strings.CutPrefix(behavior, "redirect:")
+	return ok && target != ""
+}
+
+// prefersHTML reports whether the Accept header explicitly ranks text/html
+// above JSON. Wildcards count towards JSON only.
+func prefersHTML(accept string) bool {
+	var htmlQ, jsonQ float64
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		q := 1.0
+		for _, param := range strings.Split(params, ";") {
+			if v, ok := strings.CutPrefix(strings.TrimSpace(param), "q="); ok {
+				if parsed, err := strconv.ParseFloat(v, 64); err == nil {
+					q = parsed
+				}
+			}
+		}
+		switch strings.ToLower(strings.TrimSpace(mediaType)) {
+		case "text/html":
+			htmlQ = max(htmlQ, q)
+		case "application/json", "application/*", "*/*":
+			jsonQ = max(jsonQ, q)
+		}
+	}
+	return htmlQ > jsonQ
+}
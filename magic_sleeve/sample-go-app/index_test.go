@@ -0,0 +1,63 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func getRoot(h http.Handler, accept string) (status int, contentType, body string) {
+	r := newRequest(http.MethodGet, "/", "")
+	if accept != "" {
+		r.Header.Set("Accept", accept)
+	}
+	w := serve(h, r)
+	return w.Code, w.Header().Get("Content-Type"), w.Body.String()
+}
+
+func TestRootNegotiatesIndexPage(t *testing.T) {
+	h := newTestServer(t)
+
+	status, contentType, body := getRoot(h, "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8")
+	if status != http.StatusOK || !strings.HasPrefix(contentType, "text/html") || !strings.Contains(body, `href="/health"`) {
+		t.Errorf("browser Accept got %d %s, want the index page", status, contentType)
+	}
+
+	for _, accept := range []string{"application/json", "*/*", "", "text/html;q=0.5, application/json"} {
+		status, contentType, body := getRoot(h, accept)
+		if status != http.StatusOK || !strings.HasPrefix(contentType, "application/json") || !strings.Contains(body, `"status":"healthy"`) {
+			t.Errorf("Accept %q got %d %s %s, want the health JSON", accept, status, contentType, body)
+		}
+	}
+}
+
+func TestRootBehavior(t *testing.T) {
+	for _, tc := range []struct {
+		behavior string
+		status   int
+		check    func(w *httptest.ResponseRecorder) bool
+	}{
+		{"health", http.StatusOK, func(w *httptest.ResponseRecorder) bool {
+			return strings.Contains(w.Body.String(), `"status":"healthy"`)
+		}},
+		{"404", http.StatusNotFound, func(w *httptest.ResponseRecorder) bool { return true }},
+		{"index", http.StatusOK, func(w *httptest.ResponseRecorder) bool {
+			return strings.HasPrefix(w.Header().Get("Content-Type"), "text/html")
+		}},
+		{"redirect:https://docs.example.com/api", http.StatusFound, func(w *httptest.ResponseRecorder) bool {
+			return w.Header().Get("Location") == "https://docs.example.com/api"
+		}},
+	} {
+		t.Run(tc.behavior, func(t *testing.T) {
+			h := newTestServer(t, "-root-behavior", tc.behavior)
+			w := do(h, http.MethodGet, "/", "")
+			wantStatus(t, w, tc.status)
+			if !tc.check(w) {
+				t.Errorf("unexpected response: %v %s", w.Header(), w.Body.String())
+			}
+			// /health answers the same whatever / does.
+			wantStatus(t, do(h, http.MethodGet, "/health", ""), http.StatusOK)
+		})
+	}
+}
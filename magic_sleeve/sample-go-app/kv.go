@@ -0,0 +1,45 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// flattenItems renders items as flat key-value pairs for KV-oriented
+// consumers such as Consul or etcd. Every field gets its own key,
+// item/<id>/<field>, and each tag is item/<id>/tags/<index>. The ID is
+// path-escaped so IDs containing "/" can't collide with another item's
+// keys.
+func flattenItems(items []Item) map[string]interface{} {
+	kv := make(map[string]interface{}, len(items)*6)
+	for _, item := range items {
+		prefix := "item/" + url.PathEscape(item.ID) + "/"
+		kv[prefix+"name"] = item.Name
+		kv[prefix+"value"] = item.Value
+		for i, tag := range item.Tags {
+			kv[prefix+"tags/"+strconv.Itoa(i)] = tag
+		}
+		if item.Data != nil {
+			kv[prefix+"data"] = item.Data
+		}
+		kv[prefix+"created_at"] = item.CreatedAt.Format(time.RFC3339Nano)
+		kv[prefix+"updated_at"] = item.UpdatedAt.Format(time.RFC3339Nano)
+		kv[prefix+"checksum"] = item.Checksum
+	}
+	return kv
+}
+
+func kvHandler(w http.ResponseWriter, r *http.Request) {
+	if !allowQuery(w, r) {
+		return
+	}
+	items, revision, err := store.list(r.Context())
+	if err != nil {
+		writeStoreError(w, err)
+		return
+	}
+	w.Header().Set("X-Revision", strconv.FormatUint(revision, 10))
+	writeJSON(w, http.StatusOK, flattenItems(items))
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestKVMatchesStore(t *testing.T) {
+	h := newTestServer(t)
+	wantStatus(t, do(h, http.MethodPut, "/api/items?confirm=true",
+		`[{"id":"1","name":"One","value":100,"tags":["a","b"]},{"id":"org/x","name":"Nested","value":-5,"data":{"k":[1,2]}}]`), http.StatusOK)
+	var items []Item
+	decode(t, do(h, http.MethodGet, "/api/items", ""), &items)
+
+	w := do(h, http.MethodGet, "/api/items/kv", "")
+	wantStatus(t, w, http.StatusOK)
+	var kv map[string]interface{}
+	decode(t, w, &kv)
+
+	want := map[string]interface{}{
+		"item/1/name":        "One",
+		"item/1/value":       100.0,
+		"item/1/tags/0":      "a",
+		"item/1/tags/1":      "b",
+		"item/org%2Fx/name":  "Nested",
+		"item/org%2Fx/value": -5.0,
+		"item/org%2Fx/data":  map[string]interface{}{"k": []interface{}{1.0, 2.0}},
+	}
+	for _, item := range items {
+		prefix := "item/" + url.PathEscape(item.ID) + "/"
+		want[prefix+"created_at"] = item.CreatedAt.Format(time.RFC3339Nano)
+		want[prefix+"updated_at"] = item.UpdatedAt.Format(time.RFC3339Nano)
+		want[prefix+"checksum"] = item.Checksum
+	}
+	if len(kv) != len(want) {
+		t.Errorf("got %d keys, want %d: %v", len(kv), len(want), kv)
+	}
+	for key, value := range want {
+		if got, ok := kv[key]; !ok || !jsonEqual(got, value) {
+			t.Errorf("%s = %v, want %v", key, got, value)
+		}
+	}
+}
+
+func jsonEqual(a, b interface{}) bool {
+	ja, errA := json.Marshal(a)
+	jb, errB := json.Marshal(b)
+	return errA == nil && errB == nil && string(ja) == string(jb)
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+)
+
+// urlLimitsMiddleware rejects oversized URLs and query strings before any
+// handler runs, and caps how much of the body a handler can read at
+// -max-body-size. Query parameters are counted on the raw query so an
+// abusive request is refused without parsing it.
+func urlLimitsMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if config.MaxURLLength > 0 && len(r.RequestURI) > config.MaxURLLength {
+			writeError(w, http.StatusRequestURITooLong, fmt.Sprintf("URL exceeds %d bytes", config.MaxURLLength))
+			return
+		}
+		if config.MaxQueryParams > 0 && r.URL.RawQuery != "" {
+			if n := strings.Count(r.URL.RawQuery, "&") + 1; n > config.MaxQueryParams {
+				writeError(w, http.StatusBadRequest, fmt.Sprintf("Too many query parameters (maximum %d)", config.MaxQueryParams))
+				return
+			}
+		}
+		if config.MaxBodySize > 0 {
+			r.Body = http.MaxBytesReader(w, r.Body, config.MaxBodySize)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+var errBatchTooLarge = errors.New("batch too large")
+
+// batch is a JSON array that refuses to decode more than -max-batch-size
+// entries. Elements are decoded one at a time, so an oversized batch is
+// rejected before any entry past the limit is decoded into a value. The raw
+// body has been read and syntax-checked by then; -max-body-size is what
+// bounds that.
+type batch[T any] []T
+
+func (b *batch[T]) UnmarshalJSON(data []byte) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	tok, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	if tok == nil {
+		*b = nil
+		return nil
+	}
+	if delim, ok := tok.(json.Delim); !ok || delim != '[' {
+		return errors.New("expected a JSON array")
+	}
+
+	*b = (*b)[:0]
+	for dec.More() {
+		if config.MaxBatchSize > 0 && len(*b) >= config.MaxBatchSize {
+			return errBatchTooLarge
+		}
+		var v T
+		if err := dec.Decode(&v); err != nil {
+			return err
+		}
+		*b = append(*b, v)
+	}
+	_, err = dec.Token()
+	return err
+}
+
+// allowQuery enforces -strict-query: a request carrying a query parameter
+// its handler doesn't know gets a 400 naming it, so a typo like ?srot=
+// fails loudly instead of being ignored. It reports whether the handler
+// should carry on.
+func allowQuery(w http.ResponseWriter, r *http.Request, known ...string) bool {
+	if !config.StrictQuery {
+		return true
+	}
+	for param := range r.URL.Query() {
+		if !slices.Contains(known, param) {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("Unknown query parameter %q", param))
+			return false
+		}
+	}
+	return true
+}
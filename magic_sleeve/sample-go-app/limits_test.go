@@ -0,0 +1,65 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestURLTooLong(t *testing.T) {
+	h := newTestServer(t, "-max-url-length", "64")
+	wantStatus(t, do(h, http.MethodGet, "/api/items?id_prefix="+strings.Repeat("x", 64), ""), http.StatusRequestURITooLong)
+	wantStatus(t, do(h, http.MethodGet, "/api/items?id_prefix=x", ""), http.StatusOK)
+}
+
+func TestTooManyQueryParams(t *testing.T) {
+	h := newTestServer(t, "-max-query-params", "3")
+	wantStatus(t, do(h, http.MethodGet, "/api/items?a=1&b=2&c=3&d=4", ""), http.StatusBadRequest)
+	wantStatus(t, do(h, http.MethodGet, "/api/items?a=1&b=2&c=3", ""), http.StatusOK)
+}
+
+func TestBatchTooLarge(t *testing.T) {
+	h := newTestServer(t, "-max-batch-size", "3")
+	for _, tc := range []struct{ method, target, body string }{
+		{http.MethodPut, "/api/items?confirm=true", `[{"id":"1","name":"a"},{"id":"2","name":"b"},{"id":"3","name":"c"},{"id":"4","name":"d"}]`},
+		{http.MethodPost, "/api/items/sync", `{"changes":[{"op":"delete","id":"1"},{"op":"delete","id":"2"},{"op":"delete","id":"3"},{"op":"delete","id":"4"}]}`},
+		{http.MethodPost, "/api/items/get", `{"ids":["1","2","3","4"]}`},
+	} {
+		w := do(h, tc.method, tc.target, tc.body)
+		wantStatus(t, w, http.StatusBadRequest)
+		if !strings.Contains(w.Body.String(), "maximum of 3 entries") {
+			t.Errorf("%s %s: error %s", tc.method, tc.target, w.Body.String())
+		}
+	}
+	if got := len(listIDs(t, h, "/api/items")); got != 3 {
+		t.Errorf("store holds %d items after rejected batches, want 3", got)
+	}
+	wantStatus(t, do(h, http.MethodPost, "/api/items/get", `{"ids":["1","2","3"]}`), http.StatusOK)
+}
+
+func TestBodyTooLarge(t *testing.T) {
+	h := newTestServer(t, "-max-body-size", "64")
+	w := do(h, http.MethodPost, "/api/items/get", `{"ids":["`+strings.Repeat("1", 64)+`"]}`)
+	wantStatus(t, w, http.StatusRequestEntityTooLarge)
+	wantStatus(t, do(h, http.MethodPost, "/api/items", `{"name":"Small"}`), http.StatusCreated)
+}
+
+func TestStrictQuery(t *testing.T) {
+	h := newTestServer(t, "-strict-query")
+	w := do(h, http.MethodGet, "/api/items?srot=name", "")
+	wantStatus(t, w, http.StatusBadRequest)
+	if !strings.Contains(w.Body.String(), "srot") {
+		t.Errorf("error %s does not name the parameter", w.Body.String())
+	}
+	wantStatus(t, do(h, http.MethodGet, "/api/items/1?verbose=1", ""), http.StatusBadRequest)
+
+	// Parameters the endpoint knows still work.
+	wantStatus(t, do(h, http.MethodGet, "/api/items?id_prefix=1&include_stats=true", ""), http.StatusOK)
+	wantStatus(t, do(h, http.MethodGet, "/api/items/histogram?buckets=2", ""), http.StatusOK)
+}
+
+func TestUnknownQueryIgnoredByDefault(t *testing.T) {
+	h := newTestServer(t)
+	wantStatus(t, do(h, http.MethodGet, "/api/items?srot=name", ""), http.StatusOK)
+	wantStatus(t, do(h, http.MethodGet, "/api/items/1?verbose=1", ""), http.StatusOK)
+}
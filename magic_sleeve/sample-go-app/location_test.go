@@ -0,0 +1,55 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+// copyWithoutHost copies item 1 over a raw HTTP/1.0 connection that sends
+// no Host header and returns the response's Location.
+func copyWithoutHost(t *testing.T, h http.Handler) string {
+	t.Helper()
+	srv := httptest.NewServer(h)
+	defer srv.Close()
+	conn, err := net.Dial("tcp", srv.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	body := `{"name":"Probe"}`
+	fmt.Fprintf(conn, "POST /api/items/1/copy HTTP/1.0\r\nContent-Type: application/json\r\nContent-Length: %d\r\n\r\n%s", len(body), body)
+	resp, err := http.ReadResponse(bufio.NewReader(conn), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("status = %d, want 201", resp.StatusCode)
+	}
+	return resp.Header.Get("Location")
+}
+
+func TestLocationWithoutHost(t *testing.T) {
+	h := newTestServer(t)
+	loc := copyWithoutHost(t, h)
+	u, err := url.Parse(loc)
+	if err != nil || u.IsAbs() || u.Host != "" || !strings.HasPrefix(u.Path, "/api/items/") {
+		t.Fatalf("Location = %q, want a relative /api/items/{id} path", loc)
+	}
+	wantStatus(t, do(h, http.MethodGet, loc, ""), http.StatusOK)
+}
+
+func TestLocationWithExternalURL(t *testing.T) {
+	h := newTestServer(t, "-external-url", "https://api.example.com/")
+	loc := copyWithoutHost(t, h)
+	if !strings.HasPrefix(loc, "https://api.example.com/api/items/") {
+		t.Fatalf("Location = %q, want it under -external-url", loc)
+	}
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// lockTokenHeader carries the token of an advisory lock on writes.
+const lockTokenHeader = "X-Lock-Token"
+
+type itemLock struct {
+	Token     string    `json:"token"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// lockTable holds advisory locks that external editors take on items while
+// they work on them. Locks coordinate cooperating clients; they are not a
+// security boundary.
+type lockTable struct {
+	mu    sync.Mutex
+	locks map[string]itemLock
+}
+
+var locks = &lockTable{locks: make(map[string]itemLock)}
+
+// acquire locks id for ttl. It reports false if another holder's lock on id
+// has not expired yet.
+func (t *lockTable) acquire(id string, ttl time.Duration) (itemLock, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	now := time.Now()
+	if held, exists := t.locks[id]; exists && now.Before(held.ExpiresAt) {
+		return itemLock{}, false
+	}
+	token := make([]byte, 16)
+	rand.Read(token)
+	lock := itemLock{Token: hex.EncodeToString(token), ExpiresAt: now.Add(ttl).UTC()}
+	t.locks[id] = lock
+	return lock, true
+}
+
+// release removes the lock on id if token matches it. It reports false if
+// id is locked under a different token.
+func (t *lockTable) release(id, token string) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	held, exists := t.locks[id]
+	if !exists || time.Now().After(held.ExpiresAt) {
+		delete(t.locks, id)
+		return true
+	}
+	if held.Token != token {
+		return false
+	}
+	delete(t.locks, id)
+	return true
+}
+
+// move transfers the lock on from, if it has not expired, to to, so a
+// renamed item stays locked under the same token.
+func (t *lockTable) move(from, to string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	held, exists := t.locks[from]
+	if !exists {
+		return
+	}
+	delete(t.locks, from)
+	if time.Now().Before(held.ExpiresAt) {
+		t.locks[to] = held
+	}
+}
+
+// allows reports whether a write to id presenting token may proceed: the
+// item is unlocked, its lock has expired, or token matches the lock.
+func (t *lockTable) allows(id, token string) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	held, exists := t.locks[id]
+	return !exists || time.Now().After(held.ExpiresAt) || held.Token == token
+}
+
+// anyHeld reports whether any unexpired lock exists.
+func (t *lockTable) anyHeld() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	now := time.Now()
+	for _, held := range t.locks {
+		if now.Before(held.ExpiresAt) {
+			return true
+		}
+	}
+	return false
+}
+
+// sweep periodically drops expired locks. Expiry is also checked on every
+// access, so the sweeper only reclaims memory.
+func (t *lockTable) sweep(interval time.Duration) {
+	for range time.Tick(interval) {
+		t.mu.Lock()
+		now := time.Now()
+		for id, held := range t.locks {
+			if now.After(held.ExpiresAt) {
+				delete(t.locks, id)
+			}
+		}
+		t.mu.Unlock()
+	}
+}
+
+// checkLock answers 423 and returns false if id is locked and the request
+// does not carry the lock's token.
+func checkLock(w http.ResponseWriter, r *http.Request, id string) bool {
+	if locks.allows(id, r.Header.Get(lockTokenHeader)) {
+		return true
+	}
+	writeError(w, http.StatusLocked, "Item is locked; send the lock token in the "+lockTokenHeader+" header")
+	return false
+}
+
+func lockHandler(w http.ResponseWriter, r *http.Request, id string) {
+	if !allowQuery(w, r) {
+		return
+	}
+	switch r.Method {
+	case http.MethodPost:
+		if _, err := store.get(r.Context(), id); err != nil {
+			writeStoreError(w, err)
+			return
+		}
+		lock, ok := locks.acquire(id, config.LockTTL.Duration)
+		if !ok {
+			writeError(w, http.StatusLocked, "Item is already locked")
+			return
+		}
+		writeJSON(w, http.StatusCreated, lock)
+
+	case http.MethodDelete:
+		if !locks.release(id, r.Header.Get(lockTokenHeader)) {
+			writeError(w, http.StatusLocked, "Item is locked under a different token")
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
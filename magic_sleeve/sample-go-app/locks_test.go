@@ -0,0 +1,70 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func acquireLock(t *testing.T, h http.Handler, id string) itemLock {
+	t.Helper()
+	w := do(h, http.MethodPost, "/api/items/"+id+"/lock", "")
+	wantStatus(t, w, http.StatusCreated)
+	var lock itemLock
+	decode(t, w, &lock)
+	if lock.Token == "" {
+		t.Fatal("lock has no token")
+	}
+	return lock
+}
+
+func withToken(r *http.Request, token string) *http.Request {
+	r.Header.Set(lockTokenHeader, token)
+	return r
+}
+
+func TestLockBlocksWritesWithoutToken(t *testing.T) {
+	h := newTestServer(t)
+	lock := acquireLock(t, h, "1")
+	wantStatus(t, do(h, http.MethodPost, "/api/items/1/lock", ""), http.StatusLocked)
+
+	for _, method := range []string{http.MethodPut, http.MethodPatch, http.MethodDelete} {
+		wantStatus(t, do(h, method, "/api/items/1", `{"name":"Changed"}`), http.StatusLocked)
+	}
+	wantStatus(t, serve(h, withToken(newRequest(http.MethodPut, "/api/items/1", `{"name":"Changed"}`), "wrong")), http.StatusLocked)
+	wantStatus(t, serve(h, withToken(newRequest(http.MethodPut, "/api/items/1", `{"name":"Changed"}`), lock.Token)), http.StatusOK)
+
+	// Other items are unaffected.
+	wantStatus(t, do(h, http.MethodPut, "/api/items/2", `{"name":"Changed"}`), http.StatusOK)
+
+	wantStatus(t, serve(h, withToken(newRequest(http.MethodDelete, "/api/items/1/lock", ""), "wrong")), http.StatusLocked)
+	wantStatus(t, serve(h, withToken(newRequest(http.MethodDelete, "/api/items/1/lock", ""), lock.Token)), http.StatusNoContent)
+	wantStatus(t, do(h, http.MethodPut, "/api/items/1", `{"name":"Again"}`), http.StatusOK)
+}
+
+func TestLockExpires(t *testing.T) {
+	h := newTestServer(t, "-lock-ttl", "50ms")
+	acquireLock(t, h, "1")
+	wantStatus(t, do(h, http.MethodPut, "/api/items/1", `{"name":"Changed"}`), http.StatusLocked)
+
+	time.Sleep(80 * time.Millisecond)
+	wantStatus(t, do(h, http.MethodPut, "/api/items/1", `{"name":"Changed"}`), http.StatusOK)
+	acquireLock(t, h, "1")
+}
+
+func TestLockMissingItem(t *testing.T) {
+	h := newTestServer(t)
+	wantStatus(t, do(h, http.MethodPost, "/api/items/99/lock", ""), http.StatusNotFound)
+}
+
+func TestLockedItemCannotBeRecreated(t *testing.T) {
+	h := newTestServer(t)
+	acquireLock(t, h, "1")
+	wantStatus(t, do(h, http.MethodPost, "/api/items", `{"id":"1","name":"Clobbered"}`), http.StatusConflict)
+
+	var item Item
+	decode(t, do(h, http.MethodGet, "/api/items/1", ""), &item)
+	if item.Name != "Item One" {
+		t.Errorf("locked item was overwritten with %q", item.Name)
+	}
+}
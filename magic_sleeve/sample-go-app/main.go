@@ -1,88 +1,290 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"log"
+	"net"
 	"net/http"
-	"sync"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
 	"time"
+
+	"golang.org/x/net/netutil"
 )
 
 type Item struct {
-	ID    string `json:"id"`
-	Name  string `json:"name"`
-	Value int    `json:"value"`
-}
-
-type Store struct {
-	items map[string]Item
-	mu    sync.RWMutex
-}
+	ID        string          `json:"id"`
+	Name      string          `json:"name"`
+	Value     int             `json:"value"`
+	Tags      []string        `json:"tags,omitempty"`
+	Data      json.RawMessage `json:"data,omitempty"`
+	CreatedAt time.Time       `json:"created_at"`
+	UpdatedAt time.Time       `json:"updated_at"`
+	Checksum  string          `json:"checksum"`
 
-var store = &Store{
-	items: make(map[string]Item),
+	revision uint64
 }
 
 func main() {
-	// Initialize with some sample data
-	store.mu.Lock()
-	store.items["1"] = Item{ID: "1", Name: "Item One", Value: 100}
-	store.items["2"] = Item{ID: "2", Name: "Item Two", Value: 200}
-	store.items["3"] = Item{ID: "3", Name: "Item Three", Value: 300}
-	store.mu.Unlock()
-
-	http.HandleFunc("/", healthHandler)
-	http.HandleFunc("/health", healthHandler)
-	http.HandleFunc("/items", itemsHandler)
-	http.HandleFunc("/items/", itemHandler)
-	http.HandleFunc("/api/items", itemsAPIHandler)
-	http.HandleFunc("/api/items/", itemAPIHandler)
-
-	port := ":8080"
-	log.Printf("Server starting on port %s", port)
+	parseFlags()
+
+	seedStore()
+	registerReadinessCheck("store", true, func(ctx context.Context) error {
+		_, err := store.count(ctx)
+		return err
+	})
+
+	port := fmt.Sprintf(":%d", config.Port)
+	log.Printf("Server starting on port %s (version %s, commit %s)", port, version, commit)
 	log.Printf("Health check: http://localhost%s/health", port)
 	log.Printf("Get all items: http://localhost%s/items", port)
 	log.Printf("Get item by ID: http://localhost%s/items/1", port)
-	
-	if err := http.ListenAndServe(port, nil); err != nil {
+	if config.Chaos {
+		log.Printf("Chaos mode enabled (delay: %s, error rate: %.2f)", config.ChaosDelay, config.ChaosErrorRate)
+	}
+
+	go locks.sweep(time.Second)
+
+	readOnly.Store(config.ReadOnly)
+	if config.ReadOnly {
+		log.Printf("Read-only mode enabled")
+	}
+
+	if config.StatsdAddr != "" {
+		client, err := newStatsdClient(config.StatsdAddr)
+		if err != nil {
+			log.Fatalf("Invalid -statsd-addr %q: %v", config.StatsdAddr, err)
+		}
+		batchStatsd = client
+		log.Printf("Sending StatsD metrics to %s", config.StatsdAddr)
+	}
+	handler := newHandler(batchStatsd)
+
+	ln, err := listen(port, config.MaxConnections)
+	if err != nil {
 		log.Fatal("Server failed to start:", err)
 	}
+	if config.MaxConnections > 0 {
+		log.Printf("Limiting concurrent connections to %d", config.MaxConnections)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	srv := &http.Server{Handler: handler}
+	go func() {
+		if err := srv.Serve(ln); err != nil && err != http.ErrServerClosed {
+			log.Fatal("Server failed:", err)
+		}
+	}()
+
+	<-ctx.Done()
+	log.Printf("Shutting down (timeout %s)", config.ShutdownTimeout)
+	shutdownServer(srv, config.ShutdownTimeout.Duration)
+}
+
+// listen opens the TCP listener on addr, accepting at most maxConns
+// connections at a time (0 means unlimited).
+func listen(addr string, maxConns int) (net.Listener, error) {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil || maxConns <= 0 {
+		return ln, err
+	}
+	// Connections beyond the limit wait in the accept queue until a slot
+	// frees up instead of consuming another file descriptor.
+	return netutil.LimitListener(ln, maxConns), nil
+}
+
+// newHandler registers the routes and wraps them in the middleware chain for
+// the current config. Request metrics go to statsd unless it is nil.
+func newHandler(statsd *statsdClient) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", rootHandler)
+	mux.HandleFunc("/health", healthHandler)
+	mux.HandleFunc("/readyz", readyzHandler)
+	mux.HandleFunc("/metrics", metricsHandler)
+	mux.HandleFunc("/items", itemsHandler)
+	mux.HandleFunc("/items/", itemHandler)
+	mux.HandleFunc("/api/items", itemsAPIHandler)
+	mux.HandleFunc("/api/items/", itemAPIHandler)
+	mux.HandleFunc("/admin/config", requireAdmin(adminConfigHandler))
+	mux.HandleFunc("/admin/read-only", requireAdmin(adminReadOnlyHandler))
+	mux.HandleFunc("/admin/reseed", requireAdmin(adminReseedHandler))
+
+	var handler http.Handler = mux
+	handler = cacheControlMiddleware(handler)
+	handler = readOnlyMiddleware(handler)
+	handler = disabledMethodsMiddleware(handler)
+	handler = chaosMiddleware(handler)
+	handler = urlLimitsMiddleware(handler)
+	handler = quotaMiddleware(handler)
+	if statsd != nil {
+		handler = statsdMiddleware(statsd, handler)
+	}
+	handler = deprecationMiddleware(handler)
+	return inflight.middleware(handler)
 }
 
 func healthHandler(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]interface{}{
+	writeJSON(w, http.StatusOK, map[string]interface{}{
 		"status":    "healthy",
 		"timestamp": time.Now().Format(time.RFC3339),
 		"service":   "simple-go-app",
 	})
 }
 
+type itemList struct {
+	Items interface{} `json:"items"`
+	Stats *ItemStats  `json:"stats,omitempty"`
+	// Partial is set when ?max_time ran out before every item was
+	// examined; Cursor continues the listing after the last one that was.
+	Partial bool   `json:"partial,omitempty"`
+	Cursor  string `json:"cursor,omitempty"`
+}
+
 func itemsHandler(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "application/json")
-	store.mu.RLock()
-	items := make([]Item, 0, len(store.items))
-	for _, item := range store.items {
-		items = append(items, item)
-	}
-	store.mu.RUnlock()
-	json.NewEncoder(w).Encode(items)
+	start := time.Now()
+	if !allowQuery(w, r, "include_stats", "as", "id_prefix", "created_after", "created_before", "cursor", "max_time") {
+		return
+	}
+	query := r.URL.Query()
+	includeStats := false
+	if v := query.Get("include_stats"); v != "" {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "Invalid include_stats parameter")
+			return
+		}
+		includeStats = b
+	}
+
+	// A JSON object has no defined key order, so sorting a map response
+	// would silently do nothing.
+	asMap := false
+	switch query.Get("as") {
+	case "", "array":
+	case "map":
+		if query.Has("sort") {
+			writeError(w, http.StatusBadRequest, "sort cannot be combined with as=map")
+			return
+		}
+		asMap = true
+	default:
+		writeError(w, http.StatusBadRequest, "Invalid as parameter: must be array or map")
+		return
+	}
+
+	var createdAfter, createdBefore time.Time
+	for _, bound := range []struct {
+		param string
+		t     *time.Time
+	}{{"created_after", &createdAfter}, {"created_before", &createdBefore}} {
+		v := query.Get(bound.param)
+		if v == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("Invalid %s parameter: must be an RFC3339 time", bound.param))
+			return
+		}
+		*bound.t = t
+	}
+
+	var maxTime time.Duration
+	if v := query.Get("max_time"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			writeError(w, http.StatusBadRequest, "Invalid max_time parameter: must be a positive duration such as 200ms")
+			return
+		}
+		maxTime = d
+	}
+
+	// The budget bounds the read from storage itself, so a slow backend
+	// cannot hold the response past it.
+	ctx := r.Context()
+	if maxTime > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithDeadline(ctx, start.Add(maxTime))
+		defer cancel()
+	}
+	items, revision, partial, err := listFrom(ctx, store, query.Get("cursor"))
+	if err != nil {
+		writeStoreError(w, err)
+		return
+	}
+	w.Header().Set("X-Revision", strconv.FormatUint(revision, 10))
+	var next string
+	if partial {
+		next = items[len(items)-1].ID
+	}
+	prefix := query.Get("id_prefix")
+	// The window is half-open so consecutive windows never return the same
+	// item twice.
+	matches := func(item Item) bool {
+		return strings.HasPrefix(item.ID, prefix) &&
+			(createdAfter.IsZero() || !item.CreatedAt.Before(createdAfter)) &&
+			(createdBefore.IsZero() || item.CreatedAt.Before(createdBefore))
+	}
+	items = filterItems(items, matches)
+
+	var body interface{} = items
+	if asMap {
+		byID := make(map[string]Item, len(items))
+		for _, item := range items {
+			byID[item.ID] = item
+		}
+		body = byID
+	}
+
+	if includeStats || maxTime > 0 {
+		list := itemList{Items: body, Partial: partial, Cursor: next}
+		// Stats cover every matching item, not just this page, so they
+		// take a full read that neither the cursor nor max_time limits.
+		if includeStats {
+			all, _, err := store.list(r.Context())
+			if err != nil {
+				writeStoreError(w, err)
+				return
+			}
+			stats := computeStats(filterItems(all, matches))
+			list.Stats = &stats
+		}
+		writeJSON(w, http.StatusOK, list)
+		return
+	}
+	writeJSON(w, http.StatusOK, body)
+}
+
+func filterItems(items []Item, keep func(Item) bool) []Item {
+	filtered := make([]Item, 0, len(items))
+	for _, item := range items {
+		if keep(item) {
+			filtered = append(filtered, item)
+		}
+	}
+	return filtered
 }
 
 func itemHandler(w http.ResponseWriter, r *http.Request) {
+	if !allowQuery(w, r) {
+		return
+	}
 	id := r.URL.Path[len("/items/"):]
-	store.mu.RLock()
-	item, exists := store.items[id]
-	store.mu.RUnlock()
-
-	w.Header().Set("Content-Type", "application/json")
-	if !exists {
-		w.WriteHeader(http.StatusNotFound)
-		json.NewEncoder(w).Encode(map[string]string{"error": "Item not found"})
+	item, err := store.get(r.Context(), id)
+	if err != nil {
+		writeStoreError(w, err)
 		return
 	}
-	json.NewEncoder(w).Encode(item)
+	writeItem(w, r, item)
 }
 
 func itemsAPIHandler(w http.ResponseWriter, r *http.Request) {
@@ -90,73 +292,253 @@ func itemsAPIHandler(w http.ResponseWriter, r *http.Request) {
 	case http.MethodGet:
 		itemsHandler(w, r)
 	case http.MethodPost:
+		if !allowQuery(w, r) {
+			return
+		}
 		var item Item
-		if err := json.NewDecoder(r.Body).Decode(&item); err != nil {
-			w.WriteHeader(http.StatusBadRequest)
-			json.NewEncoder(w).Encode(map[string]string{"error": "Invalid JSON"})
+		if err := decodeJSON(r, &item); err != nil {
+			writeDecodeError(w, err, "Invalid JSON")
 			return
 		}
-		store.mu.Lock()
-		if item.ID == "" {
-			item.ID = fmt.Sprintf("%d", len(store.items)+1)
+		item, err := store.create(r.Context(), item)
+		if err != nil {
+			writeStoreError(w, err)
+			return
 		}
-		store.items[item.ID] = item
-		store.mu.Unlock()
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusCreated)
-		json.NewEncoder(w).Encode(item)
+		writeJSON(w, http.StatusCreated, item)
+	case http.MethodPut:
+		replaceItemsHandler(w, r)
 	default:
 		w.WriteHeader(http.StatusMethodNotAllowed)
 	}
 }
 
+// replaceItemsHandler replaces the whole collection with the request body.
+// Every item is validated before anything is written, so a bad entry leaves
+// the store untouched.
+func replaceItemsHandler(w http.ResponseWriter, r *http.Request) {
+	if !allowQuery(w, r, "confirm") {
+		return
+	}
+	if r.URL.Query().Get("confirm") != "true" {
+		writeError(w, http.StatusBadRequest, "Replacing all items is destructive; repeat the request with ?confirm=true")
+		return
+	}
+
+	if locks.anyHeld() {
+		writeError(w, http.StatusLocked, "Cannot replace all items while any item is locked")
+		return
+	}
+
+	var items batch[Item]
+	if err := decodeJSON(r, &items); err != nil {
+		writeDecodeError(w, err, "Invalid JSON: expected an array of items")
+		return
+	}
+	start := time.Now()
+
+	seen := make(map[string]bool, len(items))
+	for i := range items {
+		items[i] = normalizeItem(items[i])
+		item := items[i]
+		if err := validateID(item.ID); err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("Item %d: %v", i, err))
+			return
+		}
+		if seen[item.ID] {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("Item %d: duplicate id %q", i, item.ID))
+			return
+		}
+		seen[item.ID] = true
+		if err := validateItem(item); err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("Item %d: %v", i, err))
+			return
+		}
+	}
+
+	summary, err := store.replaceAll(r.Context(), items)
+	if err != nil {
+		writeStoreError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, struct {
+		Summary replaceSummary `json:"summary"`
+		batchTiming
+	}{summary, finishBatch("replace", len(items), start)})
+}
+
 func itemAPIHandler(w http.ResponseWriter, r *http.Request) {
 	id := r.URL.Path[len("/api/items/"):]
-	
+	if itemID, ok := strings.CutSuffix(id, "/lock"); ok {
+		lockHandler(w, r, itemID)
+		return
+	}
+
 	switch r.Method {
 	case http.MethodGet:
-		store.mu.RLock()
-		item, exists := store.items[id]
-		store.mu.RUnlock()
-		w.Header().Set("Content-Type", "application/json")
-		if !exists {
-			w.WriteHeader(http.StatusNotFound)
-			json.NewEncoder(w).Encode(map[string]string{"error": "Item not found"})
+		switch id {
+		case "export":
+			exportHandler(w, r)
+			return
+		case "histogram":
+			histogramHandler(w, r)
+			return
+		case "kv":
+			kvHandler(w, r)
+			return
+		}
+		if !allowQuery(w, r) {
+			return
+		}
+		item, err := store.get(r.Context(), id)
+		if err != nil {
+			writeStoreError(w, err)
 			return
 		}
-		json.NewEncoder(w).Encode(item)
-		
+		writeItem(w, r, item)
+
 	case http.MethodPut:
+		if !allowQuery(w, r) {
+			return
+		}
 		var item Item
-		if err := json.NewDecoder(r.Body).Decode(&item); err != nil {
-			w.WriteHeader(http.StatusBadRequest)
-			json.NewEncoder(w).Encode(map[string]string{"error": "Invalid JSON"})
-			return
-		}
-		item.ID = id
-		store.mu.Lock()
-		store.items[id] = item
-		store.mu.Unlock()
-		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(item)
-		
+		if err := decodeJSON(r, &item); err != nil {
+			writeDecodeError(w, err, "Invalid JSON")
+			return
+		}
+		item = normalizeItem(item)
+		if err := validateItem(item); err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		if !checkLock(w, r, id) {
+			return
+		}
+		item, err := store.put(r.Context(), id, item)
+		if err != nil {
+			writeStoreError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, item)
+
+	case http.MethodPatch:
+		patchItemHandler(w, r, id)
+
 	case http.MethodDelete:
-		store.mu.Lock()
-		_, exists := store.items[id]
-		if exists {
-			delete(store.items, id)
+		if !allowQuery(w, r) {
+			return
+		}
+		if !checkLock(w, r, id) {
+			return
 		}
-		store.mu.Unlock()
-		w.Header().Set("Content-Type", "application/json")
-		if !exists {
-			w.WriteHeader(http.StatusNotFound)
-			json.NewEncoder(w).Encode(map[string]string{"error": "Item not found"})
+		err := store.delete(r.Context(), id)
+		if config.IdempotentDelete && (err == nil || errors.Is(err, errNotFound)) {
+			w.WriteHeader(http.StatusNoContent)
 			return
 		}
-		json.NewEncoder(w).Encode(map[string]string{"message": "Item deleted"})
-		
+		if err != nil {
+			writeStoreError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]string{"message": "Item deleted"})
+
+	case http.MethodPost:
+		switch id {
+		case "sync":
+			syncItemsHandler(w, r)
+			return
+		case "get":
+			getItemsHandler(w, r)
+			return
+		}
+		if srcID, ok := strings.CutSuffix(id, "/copy"); ok {
+			copyItemHandler(w, r, srcID)
+			return
+		}
+		if srcID, ok := strings.CutSuffix(id, "/rename"); ok {
+			renameItemHandler(w, r, srcID)
+			return
+		}
+		w.WriteHeader(http.StatusMethodNotAllowed)
+
 	default:
 		w.WriteHeader(http.StatusMethodNotAllowed)
 	}
 }
 
+// getItemsHandler fetches the items listed in the request body. Taking the
+// IDs in the body rather than the query string means large ID sets are not
+// limited by URL length.
+func getItemsHandler(w http.ResponseWriter, r *http.Request) {
+	if !allowQuery(w, r) {
+		return
+	}
+	var req struct {
+		IDs batch[string] `json:"ids"`
+	}
+	if err := decodeJSON(r, &req); err != nil {
+		writeDecodeError(w, err, `Invalid JSON: expected {"ids": [...]}`)
+		return
+	}
+	start := time.Now()
+
+	items, missing, err := store.getMany(r.Context(), req.IDs)
+	if err != nil {
+		writeStoreError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, struct {
+		Items    []Item   `json:"items"`
+		NotFound []string `json:"not_found"`
+		batchTiming
+	}{items, missing, finishBatch("get", len(req.IDs), start)})
+}
+
+func copyItemHandler(w http.ResponseWriter, r *http.Request, srcID string) {
+	if !allowQuery(w, r) {
+		return
+	}
+	var overrides struct {
+		Name string `json:"name"`
+	}
+	if err := decodeJSON(r, &overrides); err != nil && err != io.EOF {
+		writeDecodeError(w, err, "Invalid JSON")
+		return
+	}
+
+	item, err := store.copy(r.Context(), srcID, overrides.Name)
+	if err != nil {
+		writeStoreError(w, err)
+		return
+	}
+	w.Header().Set("Location", itemLocation(item.ID))
+	writeJSON(w, http.StatusCreated, item)
+}
+
+func renameItemHandler(w http.ResponseWriter, r *http.Request, id string) {
+	if !allowQuery(w, r) {
+		return
+	}
+	var req struct {
+		NewID string `json:"new_id"`
+	}
+	if err := decodeJSON(r, &req); err != nil {
+		writeDecodeError(w, err, `Invalid JSON: expected {"new_id": "..."}`)
+		return
+	}
+	if err := validateID(req.NewID); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid new_id: "+err.Error())
+		return
+	}
+	if !checkLock(w, r, id) {
+		return
+	}
+
+	item, err := store.rename(r.Context(), id, req.NewID)
+	if err != nil {
+		writeStoreError(w, err)
+		return
+	}
+	w.Header().Set("Location", itemLocation(item.ID))
+	writeJSON(w, http.StatusOK, item)
+}
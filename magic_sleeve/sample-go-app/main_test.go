@@ -0,0 +1,426 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTestServer configures the server from command-line style args, resets
+// the shared state to a freshly seeded store and returns the handler chain
+// main would serve.
+func newTestServer(t *testing.T, args ...string) http.Handler {
+	t.Helper()
+	var c Config
+	var raw flagValues
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	registerFlags(fs, &c, &raw)
+	if err := fs.Parse(args); err != nil {
+		t.Fatalf("parsing %q: %v", args, err)
+	}
+	for _, p := range validateConfig(&c, raw) {
+		if p.fatal {
+			t.Fatalf("invalid test config %q: %s", args, p.message)
+		}
+	}
+	saved := config
+	t.Cleanup(func() { config = saved })
+	config = c
+
+	store = newStore()
+	seedStore()
+	locks = &lockTable{locks: make(map[string]itemLock)}
+	readOnly.Store(c.ReadOnly)
+	loc, _ := time.LoadLocation(c.QuotaTimezone)
+	quotas = &quotaTable{loc: loc, counts: make(map[string]int)}
+	idGenerator = sequentialIDs{}
+	if c.IDFormat == "ulid" {
+		idGenerator = newULIDs()
+	}
+	readinessChecks = nil
+	batchStats.mu.Lock()
+	batchStats.ops = make(map[string]*batchStat)
+	batchStats.mu.Unlock()
+	batchStatsd = nil
+	if c.StatsdAddr != "" {
+		client, err := newStatsdClient(c.StatsdAddr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		batchStatsd = client
+	}
+	return newHandler(batchStatsd)
+}
+
+// newRequest builds a request for target with body, if any, as its JSON
+// body.
+func newRequest(method, target, body string) *http.Request {
+	r := httptest.NewRequest(method, target, strings.NewReader(body))
+	if body != "" {
+		r.Header.Set("Content-Type", "application/json")
+	}
+	return r
+}
+
+// serve runs r through h and returns the recorded response.
+func serve(h http.Handler, r *http.Request) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	return w
+}
+
+// do is serve for a request built by newRequest.
+func do(h http.Handler, method, target, body string) *httptest.ResponseRecorder {
+	return serve(h, newRequest(method, target, body))
+}
+
+// decode unmarshals the response body into v, failing the test if it is
+// not valid JSON.
+func decode(t *testing.T, w *httptest.ResponseRecorder, v interface{}) {
+	t.Helper()
+	if err := json.Unmarshal(w.Body.Bytes(), v); err != nil {
+		t.Fatalf("decoding %q: %v", w.Body.String(), err)
+	}
+}
+
+func wantStatus(t *testing.T, w *httptest.ResponseRecorder, status int) {
+	t.Helper()
+	if w.Code != status {
+		t.Fatalf("status = %d, want %d; body %s", w.Code, status, w.Body.String())
+	}
+}
+
+func TestListIncludeStats(t *testing.T) {
+	h := newTestServer(t)
+
+	w := do(h, http.MethodGet, "/api/items?include_stats=true", "")
+	wantStatus(t, w, http.StatusOK)
+	var list struct {
+		Items []Item     `json:"items"`
+		Stats *ItemStats `json:"stats"`
+	}
+	decode(t, w, &list)
+	want := ItemStats{Count: 3, Sum: 600, Min: 100, Max: 300, Avg: 200}
+	if len(list.Items) != 3 || list.Stats == nil || *list.Stats != want {
+		t.Fatalf("got %d items with stats %+v, want 3 items with %+v", len(list.Items), list.Stats, want)
+	}
+
+	// Stats cover the whole store, not just the page after the cursor.
+	w = do(h, http.MethodGet, "/api/items?include_stats=true&cursor=2", "")
+	decode(t, w, &list)
+	if len(list.Items) != 1 || list.Stats == nil || *list.Stats != want {
+		t.Fatalf("paged listing got %d items with stats %+v, want 1 item with %+v", len(list.Items), list.Stats, want)
+	}
+
+	// Stats follow the active filters.
+	w = do(h, http.MethodGet, "/api/items?include_stats=true&id_prefix=2", "")
+	decode(t, w, &list)
+	want = ItemStats{Count: 1, Sum: 200, Min: 200, Max: 200, Avg: 200}
+	if list.Stats == nil || *list.Stats != want {
+		t.Fatalf("filtered stats = %+v, want %+v", list.Stats, want)
+	}
+}
+
+func TestListOmitsStatsByDefault(t *testing.T) {
+	h := newTestServer(t)
+	for _, target := range []string{"/api/items", "/api/items?include_stats=false"} {
+		w := do(h, http.MethodGet, target, "")
+		wantStatus(t, w, http.StatusOK)
+		var items []Item
+		decode(t, w, &items)
+		if len(items) != 3 {
+			t.Errorf("%s: got %d items, want a plain array of 3", target, len(items))
+		}
+	}
+	wantStatus(t, do(h, http.MethodGet, "/api/items?include_stats=maybe", ""), http.StatusBadRequest)
+}
+
+func TestCopyItem(t *testing.T) {
+	h := newTestServer(t)
+	var src Item
+	decode(t, do(h, http.MethodGet, "/api/items/1", ""), &src)
+
+	w := do(h, http.MethodPost, "/api/items/1/copy", `{"name":"Copy of One"}`)
+	wantStatus(t, w, http.StatusCreated)
+	var dup Item
+	decode(t, w, &dup)
+	if dup.ID == "" || dup.ID == src.ID || dup.Name != "Copy of One" || dup.Value != src.Value {
+		t.Fatalf("copy = %+v, want a new ID, the new name and value %d", dup, src.Value)
+	}
+	if !dup.CreatedAt.After(src.CreatedAt) {
+		t.Errorf("copy created_at %s should be reset, source has %s", dup.CreatedAt, src.CreatedAt)
+	}
+	if loc := w.Header().Get("Location"); loc != "/api/items/"+dup.ID {
+		t.Errorf("Location = %q, want /api/items/%s", loc, dup.ID)
+	}
+	wantStatus(t, do(h, http.MethodGet, "/api/items/"+dup.ID, ""), http.StatusOK)
+
+	// Without a body the copy keeps the source name.
+	w = do(h, http.MethodPost, "/api/items/1/copy", "")
+	wantStatus(t, w, http.StatusCreated)
+	decode(t, w, &dup)
+	if dup.Name != src.Name {
+		t.Errorf("copy name = %q, want %q", dup.Name, src.Name)
+	}
+}
+
+func TestCopyMissingItem(t *testing.T) {
+	h := newTestServer(t)
+	wantStatus(t, do(h, http.MethodPost, "/api/items/99/copy", ""), http.StatusNotFound)
+}
+
+func TestCopyValidatesNewName(t *testing.T) {
+	h := newTestServer(t, "-max-name-length", "8")
+	wantStatus(t, do(h, http.MethodPost, "/api/items/1/copy", `{"name":"much too long"}`), http.StatusBadRequest)
+}
+
+func TestListenLimitsConnections(t *testing.T) {
+	ln, err := listen("127.0.0.1:0", 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	started := make(chan struct{}, 3)
+	release := make(chan struct{})
+	srv := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		started <- struct{}{}
+		<-release
+	})}
+	go srv.Serve(ln)
+	defer srv.Close()
+
+	done := make(chan error, 3)
+	for i := 0; i < 3; i++ {
+		go func() {
+			client := &http.Client{Transport: &http.Transport{DisableKeepAlives: true}}
+			resp, err := client.Get("http://" + ln.Addr().String())
+			if err == nil {
+				resp.Body.Close()
+			}
+			done <- err
+		}()
+	}
+
+	for i := 0; i < 2; i++ {
+		select {
+		case <-started:
+		case <-time.After(5 * time.Second):
+			t.Fatal("connections within the limit were not served")
+		}
+	}
+	select {
+	case <-started:
+		t.Fatal("a third connection was served while the limit of 2 was in use")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	// Freeing the slots lets the queued connection through.
+	close(release)
+	for i := 0; i < 3; i++ {
+		select {
+		case err := <-done:
+			if err != nil {
+				t.Errorf("request failed: %v", err)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("queued connection was never served")
+		}
+	}
+}
+
+func TestIdempotentDelete(t *testing.T) {
+	h := newTestServer(t, "-idempotent-delete")
+	for i := 0; i < 2; i++ {
+		w := do(h, http.MethodDelete, "/api/items/1", "")
+		wantStatus(t, w, http.StatusNoContent)
+		if w.Body.Len() != 0 {
+			t.Errorf("delete %d: body %q, want none", i+1, w.Body.String())
+		}
+	}
+	wantStatus(t, do(h, http.MethodGet, "/api/items/1", ""), http.StatusNotFound)
+}
+
+func TestDeleteDefault(t *testing.T) {
+	h := newTestServer(t)
+	wantStatus(t, do(h, http.MethodDelete, "/api/items/1", ""), http.StatusOK)
+	wantStatus(t, do(h, http.MethodDelete, "/api/items/1", ""), http.StatusNotFound)
+}
+
+// listIDs returns the IDs of a plain array listing, in order.
+func listIDs(t *testing.T, h http.Handler, target string) []string {
+	t.Helper()
+	w := do(h, http.MethodGet, target, "")
+	wantStatus(t, w, http.StatusOK)
+	var items []Item
+	decode(t, w, &items)
+	ids := make([]string, len(items))
+	for i, item := range items {
+		ids[i] = item.ID
+	}
+	return ids
+}
+
+func TestListIDPrefix(t *testing.T) {
+	h := newTestServer(t)
+	for _, id := range []string{"org/team/b", "org/team/a", "org/other/c"} {
+		wantStatus(t, do(h, http.MethodPost, "/api/items", `{"id":"`+id+`","name":"x"}`), http.StatusCreated)
+	}
+
+	if got := strings.Join(listIDs(t, h, "/api/items?id_prefix=org/team/"), ","); got != "org/team/a,org/team/b" {
+		t.Errorf("id_prefix=org/team/ listed %q", got)
+	}
+	if got := strings.Join(listIDs(t, h, "/api/items?id_prefix=org/team/&cursor=org/team/a"), ","); got != "org/team/b" {
+		t.Errorf("id_prefix with cursor listed %q", got)
+	}
+
+	w := do(h, http.MethodGet, "/api/items?id_prefix=nope/", "")
+	wantStatus(t, w, http.StatusOK)
+	if body := strings.TrimSpace(w.Body.String()); body != "[]" {
+		t.Errorf("no match: body %s, want []", body)
+	}
+}
+
+func TestListAsMap(t *testing.T) {
+	h := newTestServer(t)
+	w := do(h, http.MethodGet, "/api/items?as=map", "")
+	wantStatus(t, w, http.StatusOK)
+	var byID map[string]Item
+	decode(t, w, &byID)
+	if len(byID) != 3 {
+		t.Fatalf("got %d entries, want 3", len(byID))
+	}
+	for id, item := range byID {
+		if item.ID != id {
+			t.Errorf("key %q holds item %q", id, item.ID)
+		}
+	}
+
+	w = do(h, http.MethodGet, "/api/items?as=map&id_prefix=1", "")
+	byID = nil
+	decode(t, w, &byID)
+	if _, ok := byID["1"]; !ok || len(byID) != 1 {
+		t.Errorf("filtered map = %v, want only item 1", byID)
+	}
+}
+
+func TestListAsMapRejectsSort(t *testing.T) {
+	h := newTestServer(t)
+	wantStatus(t, do(h, http.MethodGet, "/api/items?as=map&sort=name", ""), http.StatusBadRequest)
+	wantStatus(t, do(h, http.MethodGet, "/api/items?as=table", ""), http.StatusBadRequest)
+}
+
+func TestReplaceAllItems(t *testing.T) {
+	h := newTestServer(t)
+	w := do(h, http.MethodPut, "/api/items?confirm=true", `[{"id":"2","name":"Two","value":2},{"id":"9","name":"Nine","value":9}]`)
+	wantStatus(t, w, http.StatusOK)
+	var resp struct {
+		Summary replaceSummary `json:"summary"`
+	}
+	decode(t, w, &resp)
+	if resp.Summary != (replaceSummary{Created: 1, Updated: 1, Deleted: 2}) {
+		t.Errorf("summary = %+v, want 1 created, 1 updated, 2 deleted", resp.Summary)
+	}
+	if got := strings.Join(listIDs(t, h, "/api/items"), ","); got != "2,9" {
+		t.Errorf("after replace the store holds %q, want 2,9", got)
+	}
+}
+
+func TestReplaceAllNeedsConfirm(t *testing.T) {
+	h := newTestServer(t)
+	for _, target := range []string{"/api/items", "/api/items?confirm=false"} {
+		wantStatus(t, do(h, http.MethodPut, target, `[{"id":"9","name":"Nine"}]`), http.StatusBadRequest)
+	}
+	if got := strings.Join(listIDs(t, h, "/api/items"), ","); got != "1,2,3" {
+		t.Errorf("unconfirmed replace changed the store to %q", got)
+	}
+}
+
+func TestReplaceAllIsAllOrNothing(t *testing.T) {
+	h := newTestServer(t)
+	wantStatus(t, do(h, http.MethodPut, "/api/items?confirm=true", `[{"id":"9","name":"Nine"},{"id":"10","name":""}]`), http.StatusBadRequest)
+	if got := strings.Join(listIDs(t, h, "/api/items"), ","); got != "1,2,3" {
+		t.Errorf("a rejected replace changed the store to %q", got)
+	}
+}
+
+func TestAutoName(t *testing.T) {
+	h := newTestServer(t, "-auto-name")
+	w := do(h, http.MethodPost, "/api/items", `{"value":5}`)
+	wantStatus(t, w, http.StatusCreated)
+	var item Item
+	decode(t, w, &item)
+	if item.Name != "Item-"+item.ID {
+		t.Errorf("name = %q, want Item-%s", item.Name, item.ID)
+	}
+
+	// A supplied name is kept.
+	w = do(h, http.MethodPost, "/api/items", `{"name":"Mine"}`)
+	decode(t, w, &item)
+	if item.Name != "Mine" {
+		t.Errorf("name = %q, want Mine", item.Name)
+	}
+}
+
+func TestEmptyNameRejectedWithoutAutoName(t *testing.T) {
+	h := newTestServer(t)
+	for _, body := range []string{`{"value":5}`, `{"name":"   "}`} {
+		w := do(h, http.MethodPost, "/api/items", body)
+		wantStatus(t, w, http.StatusBadRequest)
+		if !strings.Contains(w.Body.String(), "name is required") {
+			t.Errorf("%s: error %s", body, w.Body.String())
+		}
+	}
+}
+
+func TestBulkGet(t *testing.T) {
+	h := newTestServer(t, "-max-url-length", "1024")
+	seedItems(t, h, 3000)
+
+	// 5000 IDs, of which 1-3000 exist, in a body far beyond the URL limit.
+	ids := make([]string, 5000)
+	for i := range ids {
+		ids[i] = fmt.Sprint(5000 - i)
+	}
+	body, _ := json.Marshal(map[string][]string{"ids": ids})
+	w := do(h, http.MethodPost, "/api/items/get", string(body))
+	wantStatus(t, w, http.StatusOK)
+	var resp struct {
+		Items    []Item   `json:"items"`
+		NotFound []string `json:"not_found"`
+	}
+	decode(t, w, &resp)
+	if len(resp.Items) != 3000 || len(resp.NotFound) != 2000 {
+		t.Fatalf("got %d items and %d not found, want 3000 and 2000", len(resp.Items), len(resp.NotFound))
+	}
+	if resp.Items[0].ID != "3000" || resp.NotFound[0] != "5000" {
+		t.Errorf("results start with item %s and missing %s; want the request order", resp.Items[0].ID, resp.NotFound[0])
+	}
+}
+
+func TestListCreatedWindow(t *testing.T) {
+	h := newTestServer(t)
+	time.Sleep(5 * time.Millisecond)
+	var late Item
+	decode(t, do(h, http.MethodPost, "/api/items", `{"name":"Late"}`), &late)
+	bound := url.QueryEscape(late.CreatedAt.Format(time.RFC3339Nano))
+
+	for _, tc := range []struct{ query, want string }{
+		{"created_after=" + bound, late.ID},
+		{"created_before=" + bound, "1,2,3"},
+		{"created_after=2000-01-01T00:00:00Z&created_before=" + bound + "&id_prefix=2", "2"},
+		{"created_before=2000-01-01T00:00:00Z", ""},
+	} {
+		if got := strings.Join(listIDs(t, h, "/api/items?"+tc.query), ","); got != tc.want {
+			t.Errorf("%s listed %q, want %q", tc.query, got, tc.want)
+		}
+	}
+
+	for _, query := range []string{"created_after=yesterday", "created_before=2024-13-01T00:00:00Z"} {
+		wantStatus(t, do(h, http.MethodGet, "/api/items?"+query, ""), http.StatusBadRequest)
+	}
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// slowStore is a Storage that takes perItem to read each item, like a
+// backend streaming rows over a slow link.
+type slowStore struct {
+	*Store
+	perItem time.Duration
+}
+
+func (s slowStore) scan(ctx context.Context, after string, fn func(Item) bool) (uint64, error) {
+	return s.Store.scan(ctx, after, func(item Item) bool {
+		time.Sleep(s.perItem)
+		return fn(item)
+	})
+}
+
+func TestListMaxTimeReturnsPartial(t *testing.T) {
+	h := newTestServer(t)
+	seedItems(t, h, 20)
+	// Reading everything takes 400ms.
+	store = slowStore{Store: store.(*Store), perItem: 20 * time.Millisecond}
+
+	seen := make(map[string]bool)
+	cursor := ""
+	for pages := 0; ; pages++ {
+		if pages > 20 {
+			t.Fatal("listing never completed")
+		}
+		start := time.Now()
+		w := do(h, http.MethodGet, "/api/items?max_time=100ms&cursor="+cursor, "")
+		took := time.Since(start)
+		wantStatus(t, w, http.StatusOK)
+		var list struct {
+			Items   []Item `json:"items"`
+			Partial bool   `json:"partial"`
+			Cursor  string `json:"cursor"`
+		}
+		decode(t, w, &list)
+
+		if took > 250*time.Millisecond {
+			t.Errorf("page %d took %s with a 100ms budget", pages, took)
+		}
+		if len(list.Items) == 0 {
+			t.Fatalf("page %d is empty", pages)
+		}
+		for _, item := range list.Items {
+			if seen[item.ID] {
+				t.Errorf("item %s returned twice", item.ID)
+			}
+			seen[item.ID] = true
+		}
+		if !list.Partial {
+			if pages == 0 {
+				t.Error("first page is complete, want it cut short by max_time")
+			}
+			if list.Cursor != "" {
+				t.Errorf("complete page has cursor %q", list.Cursor)
+			}
+			break
+		}
+		if last := list.Items[len(list.Items)-1].ID; list.Cursor != last {
+			t.Fatalf("cursor = %q, want the last item %q", list.Cursor, last)
+		}
+		cursor = list.Cursor
+	}
+	for i := 1; i <= 20; i++ {
+		if !seen[fmt.Sprint(i)] {
+			t.Errorf("item %d never returned", i)
+		}
+	}
+}
+
+func TestListMaxTimeComplete(t *testing.T) {
+	h := newTestServer(t)
+	w := do(h, http.MethodGet, "/api/items?max_time=10s", "")
+	wantStatus(t, w, http.StatusOK)
+	var list struct {
+		Items   []Item `json:"items"`
+		Partial *bool  `json:"partial"`
+	}
+	decode(t, w, &list)
+	if len(list.Items) != 3 || list.Partial != nil {
+		t.Errorf("got %d items, partial %v; want all 3 and no partial flag", len(list.Items), list.Partial)
+	}
+}
+
+func TestListMaxTimeValidation(t *testing.T) {
+	h := newTestServer(t)
+	for _, v := range []string{"soon", "0s", "-5ms"} {
+		wantStatus(t, do(h, http.MethodGet, "/api/items?max_time="+v, ""), http.StatusBadRequest)
+	}
+}
+
+func TestListMaxTimeKeepsFullStats(t *testing.T) {
+	h := newTestServer(t)
+	seedItems(t, h, 20)
+	store = slowStore{Store: store.(*Store), perItem: 20 * time.Millisecond}
+
+	w := do(h, http.MethodGet, "/api/items?max_time=50ms&include_stats=true", "")
+	wantStatus(t, w, http.StatusOK)
+	var list struct {
+		Items   []Item     `json:"items"`
+		Stats   *ItemStats `json:"stats"`
+		Partial bool       `json:"partial"`
+	}
+	decode(t, w, &list)
+	if !list.Partial || list.Stats == nil || list.Stats.Count != 20 {
+		t.Errorf("got %d items, partial %v, stats %+v; want a partial page with stats over all 20", len(list.Items), list.Partial, list.Stats)
+	}
+}
+
+func TestListFromStopsOnCancel(t *testing.T) {
+	h := newTestServer(t)
+	seedItems(t, h, 5)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	// Cancellation is not a budget running out, so it is an error rather
+	// than a partial result.
+	if _, _, _, err := listFrom(ctx, store, ""); !errors.Is(err, context.Canceled) {
+		t.Errorf("listFrom returned %v, want context.Canceled", err)
+	}
+}
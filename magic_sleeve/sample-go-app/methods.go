@@ -0,0 +1,47 @@
+package main
+
+import (
+	"net/http"
+	"slices"
+	"strings"
+)
+
+// itemRouteMethods returns the methods an item endpoint serves, or nil for
+// paths that are not item endpoints.
+func itemRouteMethods(path string) []string {
+	switch {
+	case path == "/items", strings.HasPrefix(path, "/items/"):
+		return []string{http.MethodGet}
+	case path == "/api/items":
+		return []string{http.MethodGet, http.MethodPost, http.MethodPut}
+	case strings.HasPrefix(path, "/api/items/"):
+		return []string{http.MethodGet, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete}
+	}
+	return nil
+}
+
+// disabledMethodsMiddleware rejects -disabled-methods on item endpoints with
+// 405, advertising only the methods that remain enabled for the route.
+// POST /api/items/get is a read, so like read-only mode it stays available
+// when POST is disabled.
+func disabledMethodsMiddleware(next http.Handler) http.Handler {
+	if len(config.DisabledMethods) == 0 {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		methods := itemRouteMethods(r.URL.Path)
+		if methods == nil || !slices.Contains(config.DisabledMethods, r.Method) || (r.Method == http.MethodPost && !isWrite(r)) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		var allowed []string
+		for _, m := range methods {
+			if !slices.Contains(config.DisabledMethods, m) {
+				allowed = append(allowed, m)
+			}
+		}
+		w.Header().Set("Allow", strings.Join(allowed, ", "))
+		writeError(w, http.StatusMethodNotAllowed, r.Method+" is disabled on this server")
+	})
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestDisabledMethods(t *testing.T) {
+	h := newTestServer(t, "-disabled-methods", "post,PUT,PATCH,DELETE", "-admin-token", "s3cret")
+
+	w := do(h, http.MethodDelete, "/api/items/1", "")
+	wantStatus(t, w, http.StatusMethodNotAllowed)
+	if allow := w.Header().Get("Allow"); allow != "GET" {
+		t.Errorf("Allow = %q, want GET", allow)
+	}
+	wantStatus(t, do(h, http.MethodPost, "/api/items", `{"name":"New"}`), http.StatusMethodNotAllowed)
+	wantStatus(t, do(h, http.MethodGet, "/api/items/1", ""), http.StatusOK)
+	// Bulk get is a read carried in a POST body.
+	wantStatus(t, do(h, http.MethodPost, "/api/items/get", `{"ids":["1"]}`), http.StatusOK)
+
+	// Only item endpoints are affected.
+	wantStatus(t, serve(h, adminRequest(http.MethodPost, "/admin/reseed", "")), http.StatusOK)
+}
+
+func TestDisabledMethodsAllowHeaderPerRoute(t *testing.T) {
+	h := newTestServer(t, "-disabled-methods", "DELETE")
+	w := do(h, http.MethodDelete, "/api/items/1", "")
+	wantStatus(t, w, http.StatusMethodNotAllowed)
+	if allow := w.Header().Get("Allow"); allow != "GET, POST, PUT, PATCH" {
+		t.Errorf("Allow = %q, want GET, POST, PUT, PATCH", allow)
+	}
+	wantStatus(t, do(h, http.MethodPut, "/api/items/1", `{"name":"Still writable"}`), http.StatusOK)
+}
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"runtime"
+	"strings"
+)
+
+// Set at build time with -ldflags "-X main.version=... -X main.commit=...".
+var (
+	version = "dev"
+	commit  = "unknown"
+)
+
+// metricsHandler serves metrics in the Prometheus text exposition format.
+func metricsHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	fmt.Fprintln(w, "# HELP codelabs_build_info Build information about the running binary.")
+	fmt.Fprintln(w, "# TYPE codelabs_build_info gauge")
+	fmt.Fprintf(w, "codelabs_build_info{commit=%s,go_version=%s,version=%s} 1\n",
+		labelValue(commit), labelValue(runtime.Version()), labelValue(version))
+	writeBatchMetrics(w)
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func labelValue(v string) string {
+	return `"` + labelEscaper.Replace(v) + `"`
+}
@@ -0,0 +1,25 @@
+package main
+
+import (
+	"net/http"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestBuildInfoMetric(t *testing.T) {
+	h := newTestServer(t)
+	savedVersion, savedCommit := version, commit
+	t.Cleanup(func() { version, commit = savedVersion, savedCommit })
+	version, commit = "1.2.3", `ab"cd`
+
+	w := do(h, http.MethodGet, "/metrics", "")
+	wantStatus(t, w, http.StatusOK)
+	want := `codelabs_build_info{commit="ab\"cd",go_version="` + runtime.Version() + `",version="1.2.3"} 1`
+	if !strings.Contains(w.Body.String(), want+"\n") {
+		t.Errorf("/metrics is missing %s:\n%s", want, w.Body.String())
+	}
+	if !strings.Contains(w.Body.String(), "# TYPE codelabs_build_info gauge\n") {
+		t.Error("/metrics does not declare codelabs_build_info as a gauge")
+	}
+}
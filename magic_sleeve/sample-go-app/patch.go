@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+)
+
+// itemPatch holds the fields a PATCH request sets. Fields left out of the
+// request are nil and keep their stored value.
+type itemPatch struct {
+	ID    *string   `json:"id"`
+	Name  *string   `json:"name"`
+	Value *int      `json:"value"`
+	Tags  *[]string `json:"tags"`
+	// Data is left as raw JSON: nil when absent, "null" to clear it.
+	Data json.RawMessage `json:"data"`
+}
+
+func (p itemPatch) apply(item Item) Item {
+	if p.ID != nil {
+		item.ID = *p.ID
+	}
+	if p.Name != nil {
+		item.Name = *p.Name
+	}
+	if p.Value != nil {
+		item.Value = *p.Value
+	}
+	if p.Tags != nil {
+		item.Tags = *p.Tags
+	}
+	if p.Data != nil {
+		item.Data = p.Data
+	}
+	return item
+}
+
+// patch merges p into the item stored under id. A missing item is an
+// errNotFound unless -patch-upsert is set, in which case it is created from
+// the supplied fields alone and must be valid on its own. created reports
+// which of the two happened.
+func (s *Store) patch(_ context.Context, id string, p itemPatch) (item Item, created bool, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	existing, exists := s.items[id]
+	if !exists && !config.PatchUpsert {
+		return Item{}, false, errNotFound
+	}
+
+	item = normalizeItem(p.apply(existing))
+	if exists {
+		if field := immutableViolation(existing, item); field != "" {
+			return Item{}, false, &immutableError{field: field}
+		}
+	} else if item.ID != "" && item.ID != id {
+		return Item{}, false, &validationError{err: errors.New("id does not match the URL")}
+	} else if err := validateID(id); err != nil {
+		return Item{}, false, &validationError{err: err}
+	}
+	item.ID = id
+	if err := validateItem(item); err != nil {
+		return Item{}, false, &validationError{err: err}
+	}
+	item, err = s.putLocked(item)
+	return item, !exists, err
+}
+
+func patchItemHandler(w http.ResponseWriter, r *http.Request, id string) {
+	if !allowQuery(w, r) {
+		return
+	}
+	var p itemPatch
+	if err := decodeJSON(r, &p); err != nil {
+		writeDecodeError(w, err, "Invalid JSON")
+		return
+	}
+	if !checkLock(w, r, id) {
+		return
+	}
+	item, created, err := store.patch(r.Context(), id, p)
+	if err != nil {
+		writeStoreError(w, err)
+		return
+	}
+	if created {
+		w.Header().Set("Location", itemLocation(item.ID))
+		writeJSON(w, http.StatusCreated, item)
+		return
+	}
+	writeJSON(w, http.StatusOK, item)
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestPatchMergesFields(t *testing.T) {
+	h := newTestServer(t)
+	wantStatus(t, do(h, http.MethodPut, "/api/items/1", `{"name":"One","value":1,"tags":["a"]}`), http.StatusOK)
+
+	w := do(h, http.MethodPatch, "/api/items/1", `{"value":2}`)
+	wantStatus(t, w, http.StatusOK)
+	var item Item
+	decode(t, w, &item)
+	if item.Name != "One" || item.Value != 2 || len(item.Tags) != 1 {
+		t.Errorf("patched item = %+v, want only value changed", item)
+	}
+
+	var cleared Item
+	decode(t, do(h, http.MethodPatch, "/api/items/1", `{"tags":[]}`), &cleared)
+	if len(cleared.Tags) != 0 || cleared.Value != 2 {
+		t.Errorf("item = %+v after patching in no tags, want them cleared", cleared)
+	}
+}
+
+func TestPatchMissingItemDefault(t *testing.T) {
+	h := newTestServer(t)
+	wantStatus(t, do(h, http.MethodPatch, "/api/items/99", `{"name":"New"}`), http.StatusNotFound)
+	wantStatus(t, do(h, http.MethodGet, "/api/items/99", ""), http.StatusNotFound)
+}
+
+func TestPatchUpsert(t *testing.T) {
+	h := newTestServer(t, "-patch-upsert")
+	w := do(h, http.MethodPatch, "/api/items/99", `{"name":"New","value":7}`)
+	wantStatus(t, w, http.StatusCreated)
+	if loc := w.Header().Get("Location"); loc != "/api/items/99" {
+		t.Errorf("Location = %q, want /api/items/99", loc)
+	}
+	var item Item
+	decode(t, w, &item)
+	if item.ID != "99" || item.Name != "New" || item.Value != 7 {
+		t.Errorf("created item = %+v", item)
+	}
+
+	// Patching it again is an ordinary update.
+	wantStatus(t, do(h, http.MethodPatch, "/api/items/99", `{"value":8}`), http.StatusOK)
+}
+
+func TestPatchUpsertValidatesCreatedItem(t *testing.T) {
+	h := newTestServer(t, "-patch-upsert")
+	for _, tc := range []struct{ target, body, want string }{
+		// The supplied fields alone don't make a complete item.
+		{"/api/items/99", `{"value":7}`, "name is required"},
+		{"/api/items/99", `{"id":"98","name":"New"}`, "id does not match"},
+		{"/api/items/export", `{"name":"New"}`, "reserved"},
+	} {
+		w := do(h, http.MethodPatch, tc.target, tc.body)
+		wantStatus(t, w, http.StatusBadRequest)
+		if !strings.Contains(w.Body.String(), tc.want) {
+			t.Errorf("%s %s: error %s, want %q", tc.target, tc.body, w.Body.String(), tc.want)
+		}
+	}
+	wantStatus(t, do(h, http.MethodGet, "/api/items/99", ""), http.StatusNotFound)
+}
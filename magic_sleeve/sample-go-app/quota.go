@@ -0,0 +1,91 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	// The runtime image ships without a zoneinfo database, so embed one
+	// for -quota-timezone.
+	_ "time/tzdata"
+)
+
+const apiKeyHeader = "X-API-Key"
+
+// quotaTable counts requests per API key for the current day in
+// -quota-timezone. Counts only live in memory and start over on restart.
+type quotaTable struct {
+	mu     sync.Mutex
+	loc    *time.Location
+	day    string
+	counts map[string]int
+}
+
+var quotas = &quotaTable{loc: time.UTC, counts: make(map[string]int)}
+
+// unlistedKeys is the quota bucket shared by every key not in -quota-keys.
+// Keys are not authenticated, so giving each unlisted value its own count
+// would let a client reset its quota by changing the header, and would let
+// clients grow the table without bound.
+const unlistedKeys = ""
+
+// quotaFor returns the bucket key is counted in and its daily quota, or 0
+// if it is unlimited.
+func quotaFor(key string) (bucket string, limit int) {
+	if limit, ok := config.QuotaKeys[key]; ok {
+		return key, limit
+	}
+	return unlistedKeys, config.QuotaDefault
+}
+
+// take counts a request against the bucket key and reports how many
+// requests it has left today. Requests over the quota are refused and not
+// counted.
+func (q *quotaTable) take(key string, limit int, now time.Time) (remaining int, ok bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if day := now.In(q.loc).Format("2006-01-02"); day != q.day {
+		q.day = day
+		q.counts = make(map[string]int)
+	}
+	if q.counts[key] >= limit {
+		return 0, false
+	}
+	q.counts[key]++
+	return limit - q.counts[key], true
+}
+
+// untilReset returns the time left until the next midnight in q's zone.
+func (q *quotaTable) untilReset(now time.Time) time.Duration {
+	local := now.In(q.loc)
+	midnight := time.Date(local.Year(), local.Month(), local.Day()+1, 0, 0, 0, 0, q.loc)
+	return midnight.Sub(local)
+}
+
+// quotaMiddleware enforces the daily quota of the API key named in
+// X-API-Key. Requests without a key, and keys whose quota is 0, are not
+// limited.
+func quotaMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get(apiKeyHeader)
+		bucket, limit := quotaFor(key)
+		if key == "" || limit <= 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		now := time.Now()
+		remaining, ok := quotas.take(bucket, limit, now)
+		w.Header().Set("X-Quota-Limit", strconv.Itoa(limit))
+		w.Header().Set("X-Quota-Remaining", strconv.Itoa(remaining))
+		if !ok {
+			retry := quotas.untilReset(now).Round(time.Second)
+			w.Header().Set("Retry-After", strconv.Itoa(int(retry.Seconds())))
+			writeError(w, http.StatusTooManyRequests, fmt.Sprintf("Daily quota of %d requests exhausted", limit))
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
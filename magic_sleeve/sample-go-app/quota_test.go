@@ -0,0 +1,73 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func withAPIKey(key string) *http.Request {
+	r := newRequest(http.MethodGet, "/api/items", "")
+	r.Header.Set(apiKeyHeader, key)
+	return r
+}
+
+func TestQuotaExhaustion(t *testing.T) {
+	h := newTestServer(t, "-quota-keys", "alice=3", "-quota-default", "1")
+	for want := 2; want >= 0; want-- {
+		w := serve(h, withAPIKey("alice"))
+		wantStatus(t, w, http.StatusOK)
+		if got := w.Header().Get("X-Quota-Remaining"); got != strconv.Itoa(want) {
+			t.Errorf("X-Quota-Remaining = %s, want %d", got, want)
+		}
+	}
+
+	w := serve(h, withAPIKey("alice"))
+	wantStatus(t, w, http.StatusTooManyRequests)
+	if got := w.Header().Get("X-Quota-Remaining"); got != "0" {
+		t.Errorf("X-Quota-Remaining = %s on a refused request, want 0", got)
+	}
+	if retry, err := strconv.Atoi(w.Header().Get("Retry-After")); err != nil || retry <= 0 || retry > 24*60*60 {
+		t.Errorf("Retry-After = %q, want the seconds until midnight", w.Header().Get("Retry-After"))
+	}
+
+	// Unlisted keys share the default quota, so a new key value does not
+	// bring a fresh allowance.
+	wantStatus(t, serve(h, withAPIKey("bob")), http.StatusOK)
+	wantStatus(t, serve(h, withAPIKey("bob")), http.StatusTooManyRequests)
+	wantStatus(t, serve(h, withAPIKey("carol")), http.StatusTooManyRequests)
+	// Requests without a key are not limited.
+	wantStatus(t, do(h, http.MethodGet, "/api/items", ""), http.StatusOK)
+}
+
+func TestQuotaResetsAtMidnight(t *testing.T) {
+	q := &quotaTable{loc: time.FixedZone("UTC+2", 2*60*60), counts: make(map[string]int)}
+	beforeMidnight := time.Date(2024, 5, 1, 21, 59, 0, 0, time.UTC)
+	if _, ok := q.take("alice", 1, beforeMidnight); !ok {
+		t.Fatal("first request refused")
+	}
+	if _, ok := q.take("alice", 1, beforeMidnight); ok {
+		t.Fatal("request over the quota allowed")
+	}
+	if got := q.untilReset(beforeMidnight); got != time.Minute {
+		t.Errorf("untilReset = %s, want 1m0s", got)
+	}
+	if _, ok := q.take("alice", 1, beforeMidnight.Add(time.Minute)); !ok {
+		t.Error("quota did not reset at midnight in the configured zone")
+	}
+}
+
+func TestQuotaTableStaysBounded(t *testing.T) {
+	h := newTestServer(t, "-quota-keys", "alice=3", "-quota-default", "1000")
+	for i := 0; i < 50; i++ {
+		wantStatus(t, serve(h, withAPIKey(strconv.Itoa(i))), http.StatusOK)
+	}
+	wantStatus(t, serve(h, withAPIKey("alice")), http.StatusOK)
+	quotas.mu.Lock()
+	n := len(quotas.counts)
+	quotas.mu.Unlock()
+	if n != 2 {
+		t.Errorf("quota table has %d entries after 51 keys, want 2 (alice and the shared bucket)", n)
+	}
+}
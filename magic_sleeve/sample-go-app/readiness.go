@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+)
+
+type readinessCheck struct {
+	name string
+	// critical checks always affect readiness; under the degraded policy
+	// other failing checks are only reported as warnings.
+	critical bool
+	check    func(ctx context.Context) error
+}
+
+var readinessChecks []readinessCheck
+
+func registerReadinessCheck(name string, critical bool, check func(ctx context.Context) error) {
+	readinessChecks = append(readinessChecks, readinessCheck{name: name, critical: critical, check: check})
+}
+
+type checkResult struct {
+	Status   string `json:"status"`
+	Critical bool   `json:"critical"`
+	Error    string `json:"error,omitempty"`
+}
+
+// runCheck runs c with the -readiness-timeout deadline. A check that ignores
+// its context is abandoned when the deadline passes so it cannot hold up
+// the probe.
+func runCheck(ctx context.Context, c readinessCheck) error {
+	ctx, cancel := context.WithTimeout(ctx, config.ReadinessTimeout.Duration)
+	defer cancel()
+
+	done := make(chan error, 1)
+	go func() { done <- c.check(ctx) }()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return fmt.Errorf("timed out after %s", config.ReadinessTimeout)
+	}
+}
+
+func readyzHandler(w http.ResponseWriter, r *http.Request) {
+	results := make(map[string]checkResult, len(readinessChecks))
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, c := range readinessChecks {
+		wg.Add(1)
+		go func(c readinessCheck) {
+			defer wg.Done()
+			result := checkResult{Status: "ok", Critical: c.critical}
+			if err := runCheck(r.Context(), c); err != nil {
+				result.Status = "failed"
+				result.Error = err.Error()
+			}
+			mu.Lock()
+			results[c.name] = result
+			mu.Unlock()
+		}(c)
+	}
+	wg.Wait()
+
+	status := "ready"
+	for _, result := range results {
+		if result.Status == "ok" {
+			continue
+		}
+		if result.Critical || config.ReadinessPolicy == "strict" {
+			status = "not_ready"
+			break
+		}
+		status = "degraded"
+	}
+
+	code := http.StatusOK
+	if status == "not_ready" {
+		code = http.StatusServiceUnavailable
+	}
+	writeJSON(w, code, map[string]interface{}{
+		"status": status,
+		"checks": results,
+	})
+}
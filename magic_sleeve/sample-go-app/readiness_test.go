@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+type readyzResponse struct {
+	Status string                 `json:"status"`
+	Checks map[string]checkResult `json:"checks"`
+}
+
+func getReadyz(t *testing.T, h http.Handler, status int) readyzResponse {
+	t.Helper()
+	w := do(h, http.MethodGet, "/readyz", "")
+	wantStatus(t, w, status)
+	var resp readyzResponse
+	decode(t, w, &resp)
+	return resp
+}
+
+func TestReadyzSlowCheckTimesOut(t *testing.T) {
+	h := newTestServer(t, "-readiness-timeout", "50ms")
+	hang := make(chan struct{})
+	defer close(hang)
+	// The check ignores its context, so only the probe's own deadline can
+	// stop it from hanging.
+	registerReadinessCheck("slow", true, func(context.Context) error {
+		<-hang
+		return nil
+	})
+
+	start := time.Now()
+	resp := getReadyz(t, h, http.StatusServiceUnavailable)
+	if took := time.Since(start); took > time.Second {
+		t.Errorf("probe took %s with a 50ms check timeout", took)
+	}
+	if c := resp.Checks["slow"]; c.Status != "failed" || !strings.Contains(c.Error, "timed out") {
+		t.Errorf("slow check = %+v, want a timeout failure", c)
+	}
+}
+
+func TestReadyzDegradedPolicy(t *testing.T) {
+	failing := func(context.Context) error { return errors.New("cache unreachable") }
+	ok := func(context.Context) error { return nil }
+
+	h := newTestServer(t, "-readiness-policy", "degraded")
+	registerReadinessCheck("store", true, ok)
+	registerReadinessCheck("cache", false, failing)
+	resp := getReadyz(t, h, http.StatusOK)
+	if resp.Status != "degraded" || resp.Checks["cache"].Error != "cache unreachable" {
+		t.Errorf("readyz = %+v, want degraded with the cache error reported", resp)
+	}
+
+	// A critical failure still takes the server out of rotation.
+	registerReadinessCheck("database", true, failing)
+	if resp := getReadyz(t, h, http.StatusServiceUnavailable); resp.Status != "not_ready" {
+		t.Errorf("status = %q, want not_ready", resp.Status)
+	}
+
+	h = newTestServer(t)
+	registerReadinessCheck("cache", false, failing)
+	if resp := getReadyz(t, h, http.StatusServiceUnavailable); resp.Status != "not_ready" {
+		t.Errorf("strict policy status = %q, want not_ready", resp.Status)
+	}
+}
+
+func TestReadyzExemptFromChaos(t *testing.T) {
+	h := newTestServer(t, "-chaos", "-chaos-error-rate", "1.0")
+	registerReadinessCheck("store", true, func(context.Context) error { return nil })
+	for i := 0; i < 10; i++ {
+		getReadyz(t, h, http.StatusOK)
+	}
+}
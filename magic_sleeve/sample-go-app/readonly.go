@@ -0,0 +1,55 @@
+package main
+
+import (
+	"net/http"
+	"sync/atomic"
+)
+
+// readOnly starts from -read-only and can be flipped at runtime through
+// /admin/read-only.
+var readOnly atomic.Bool
+
+// isWrite reports whether r may modify items. POST /api/items/get only
+// uses POST to carry its ID list in the body, so it counts as a read.
+func isWrite(r *http.Request) bool {
+	switch r.Method {
+	case http.MethodPost:
+		return r.URL.Path != "/api/items/get"
+	case http.MethodPut, http.MethodPatch, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+// readOnlyMiddleware rejects every write while the store is read-only,
+// including /admin/reseed. Only /admin/read-only stays writable so the mode
+// can be switched off again.
+func readOnlyMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if readOnly.Load() && isWrite(r) && r.URL.Path != "/admin/read-only" {
+			w.Header().Set("Allow", "GET, HEAD")
+			writeError(w, http.StatusMethodNotAllowed, "Server is in read-only mode")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func adminReadOnlyHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPut:
+		var body struct {
+			ReadOnly *bool `json:"read_only"`
+		}
+		if err := decodeJSON(r, &body); err != nil || body.ReadOnly == nil {
+			writeError(w, http.StatusBadRequest, `Invalid JSON: expected {"read_only": true|false}`)
+			return
+		}
+		readOnly.Store(*body.ReadOnly)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]bool{"read_only": readOnly.Load()})
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestReadOnlyRejectsWrites(t *testing.T) {
+	h := newTestServer(t, "-read-only", "-admin-token", "s3cret")
+	for _, tc := range []struct{ method, target, body string }{
+		{http.MethodPost, "/api/items", `{"name":"New"}`},
+		{http.MethodPut, "/api/items/1", `{"name":"Changed"}`},
+		{http.MethodPatch, "/api/items/1", `{"name":"Changed"}`},
+		{http.MethodDelete, "/api/items/1", ""},
+		{http.MethodPut, "/api/items?confirm=true", `[]`},
+		{http.MethodPost, "/api/items/sync", `{"changes":[{"op":"delete","id":"1"}]}`},
+		{http.MethodPost, "/admin/reseed", ""},
+	} {
+		r := adminRequest(tc.method, tc.target, tc.body)
+		w := serve(h, r)
+		if w.Code != http.StatusMethodNotAllowed {
+			t.Errorf("%s %s: status %d, want 405", tc.method, tc.target, w.Code)
+		}
+	}
+
+	if got := len(listIDs(t, h, "/api/items")); got != 3 {
+		t.Errorf("store holds %d items after rejected writes, want 3", got)
+	}
+	wantStatus(t, do(h, http.MethodGet, "/api/items/1", ""), http.StatusOK)
+	wantStatus(t, do(h, http.MethodPost, "/api/items/get", `{"ids":["1"]}`), http.StatusOK)
+}
+
+func TestReadOnlyToggle(t *testing.T) {
+	h := newTestServer(t, "-admin-token", "s3cret")
+	wantStatus(t, serve(h, adminRequest(http.MethodPut, "/admin/read-only", `{"read_only":true}`)), http.StatusOK)
+	wantStatus(t, do(h, http.MethodDelete, "/api/items/1", ""), http.StatusMethodNotAllowed)
+
+	// The toggle itself stays writable so the mode can be switched off.
+	wantStatus(t, serve(h, adminRequest(http.MethodPut, "/admin/read-only", `{"read_only":false}`)), http.StatusOK)
+	wantStatus(t, do(h, http.MethodDelete, "/api/items/1", ""), http.StatusOK)
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestRenameItem(t *testing.T) {
+	h := newTestServer(t)
+	before := do(h, http.MethodGet, "/api/items/1", "")
+	wantStatus(t, before, http.StatusOK)
+	var original Item
+	decode(t, before, &original)
+
+	w := do(h, http.MethodPost, "/api/items/1/rename", `{"new_id":"first item"}`)
+	wantStatus(t, w, http.StatusOK)
+	if got, want := w.Header().Get("Location"), "/api/items/first%20item"; got != want {
+		t.Errorf("Location = %q, want %q", got, want)
+	}
+	var renamed Item
+	decode(t, w, &renamed)
+	if renamed.ID != "first item" || renamed.Name != original.Name || !renamed.CreatedAt.Equal(original.CreatedAt) {
+		t.Errorf("renamed item = %+v, want %+v under the new ID", renamed, original)
+	}
+
+	wantStatus(t, do(h, http.MethodGet, "/api/items/1", ""), http.StatusNotFound)
+	wantStatus(t, do(h, http.MethodGet, "/api/items/first%20item", ""), http.StatusOK)
+}
+
+func TestRenameConflicts(t *testing.T) {
+	h := newTestServer(t)
+	wantStatus(t, do(h, http.MethodPost, "/api/items/1/rename", `{"new_id":"2"}`), http.StatusConflict)
+	wantStatus(t, do(h, http.MethodPost, "/api/items/99/rename", `{"new_id":"100"}`), http.StatusNotFound)
+
+	// Neither item moved.
+	wantStatus(t, do(h, http.MethodGet, "/api/items/1", ""), http.StatusOK)
+	wantStatus(t, do(h, http.MethodGet, "/api/items/2", ""), http.StatusOK)
+}
+
+func TestRenameValidatesNewID(t *testing.T) {
+	h := newTestServer(t)
+	for _, body := range []string{`{}`, `{"new_id":" padded"}`, `{"new_id":"export"}`, `{"new_id":"x/lock"}`} {
+		wantStatus(t, do(h, http.MethodPost, "/api/items/1/rename", body), http.StatusBadRequest)
+	}
+	wantStatus(t, do(h, http.MethodGet, "/api/items/1", ""), http.StatusOK)
+}
+
+func TestCreateRejectsReservedID(t *testing.T) {
+	h := newTestServer(t)
+	wantStatus(t, do(h, http.MethodPost, "/api/items", `{"id":"export","name":"Shadow"}`), http.StatusBadRequest)
+	wantStatus(t, do(h, http.MethodPut, "/api/items?confirm=true", `[{"id":"kv","name":"Shadow"}]`), http.StatusBadRequest)
+}
+
+func TestRenameKeepsUniqueIndex(t *testing.T) {
+	h := newTestServer(t, "-unique-on", "name")
+	w := do(h, http.MethodPost, "/api/items", `{"name":"Widget"}`)
+	wantStatus(t, w, http.StatusCreated)
+	var widget Item
+	decode(t, w, &widget)
+
+	wantStatus(t, do(h, http.MethodPost, "/api/items/"+widget.ID+"/rename", `{"new_id":"widget"}`), http.StatusOK)
+	wantStatus(t, do(h, http.MethodPost, "/api/items", `{"name":"Widget"}`), http.StatusConflict)
+
+	// The name is released when the item is deleted under its new ID.
+	wantStatus(t, do(h, http.MethodDelete, "/api/items/widget", ""), http.StatusOK)
+	wantStatus(t, do(h, http.MethodPost, "/api/items", `{"name":"Widget"}`), http.StatusCreated)
+}
+
+func TestRenameMovesLock(t *testing.T) {
+	h := newTestServer(t)
+	lock := acquireLock(t, h, "1")
+	wantStatus(t, do(h, http.MethodPost, "/api/items/1/rename", `{"new_id":"one"}`), http.StatusLocked)
+	wantStatus(t, serve(h, withToken(newRequest(http.MethodPost, "/api/items/1/rename", `{"new_id":"one"}`), lock.Token)), http.StatusOK)
+
+	wantStatus(t, do(h, http.MethodPut, "/api/items/one", `{"name":"Changed"}`), http.StatusLocked)
+	wantStatus(t, serve(h, withToken(newRequest(http.MethodPut, "/api/items/one", `{"name":"Changed"}`), lock.Token)), http.StatusOK)
+
+	// A new item under the old ID starts unlocked.
+	wantStatus(t, do(h, http.MethodPut, "/api/items/1", `{"name":"Fresh"}`), http.StatusOK)
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// inflightTracker records the requests currently being served so a forced
+// shutdown can report which ones were cut off.
+type inflightTracker struct {
+	mu       sync.Mutex
+	nextID   uint64
+	requests map[uint64]string
+}
+
+var inflight = &inflightTracker{requests: make(map[uint64]string)}
+
+func (t *inflightTracker) middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.mu.Lock()
+		t.nextID++
+		id := t.nextID
+		t.requests[id] = r.Method + " " + r.URL.Path
+		t.mu.Unlock()
+
+		defer func() {
+			t.mu.Lock()
+			delete(t.requests, id)
+			t.mu.Unlock()
+		}()
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (t *inflightTracker) snapshot() []string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	requests := make([]string, 0, len(t.requests))
+	for _, req := range t.requests {
+		requests = append(requests, req)
+	}
+	sort.Strings(requests)
+	return requests
+}
+
+// shutdownServer drains srv for up to timeout, then force-closes whatever
+// is left and logs the requests that did not finish.
+func shutdownServer(srv *http.Server, timeout time.Duration) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	if err := srv.Shutdown(ctx); err == nil {
+		log.Printf("Server stopped")
+		return
+	}
+
+	unfinished := inflight.snapshot()
+	log.Printf("Shutdown deadline of %s exceeded with %d request(s) in flight; forcing close", timeout, len(unfinished))
+	for _, req := range unfinished {
+		log.Printf("  unfinished: %s", req)
+	}
+	if err := srv.Close(); err != nil {
+		log.Printf("Forced close failed: %v", err)
+	}
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"bytes"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// syncBuffer is a bytes.Buffer safe to log into from several goroutines.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+// captureLog redirects the standard logger for the rest of the test.
+func captureLog(t *testing.T) *syncBuffer {
+	var buf syncBuffer
+	log.SetOutput(&buf)
+	t.Cleanup(func() { log.SetOutput(os.Stderr) })
+	return &buf
+}
+
+func TestShutdownLogsUnfinishedRequests(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	started := make(chan struct{})
+	release := make(chan struct{})
+	defer close(release)
+	srv := &http.Server{Handler: inflight.middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+	}))}
+	go srv.Serve(ln)
+
+	go http.Get("http://" + ln.Addr().String() + "/slow")
+	select {
+	case <-started:
+	case <-time.After(5 * time.Second):
+		t.Fatal("slow request never reached the handler")
+	}
+
+	logs := captureLog(t)
+	shutdownServer(srv, 50*time.Millisecond)
+	out := logs.String()
+	if !strings.Contains(out, "exceeded with 1 request(s) in flight") {
+		t.Errorf("log does not report the in-flight count:\n%s", out)
+	}
+	if !strings.Contains(out, "unfinished: GET /slow") {
+		t.Errorf("log does not name the unfinished request:\n%s", out)
+	}
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+type ItemStats struct {
+	Count int     `json:"count"`
+	Sum   int     `json:"sum"`
+	Min   int     `json:"min"`
+	Max   int     `json:"max"`
+	Avg   float64 `json:"avg"`
+}
+
+func computeStats(items []Item) ItemStats {
+	var stats ItemStats
+	for i, item := range items {
+		if i == 0 || item.Value < stats.Min {
+			stats.Min = item.Value
+		}
+		if i == 0 || item.Value > stats.Max {
+			stats.Max = item.Value
+		}
+		stats.Sum += item.Value
+	}
+	stats.Count = len(items)
+	if stats.Count > 0 {
+		stats.Avg = float64(stats.Sum) / float64(stats.Count)
+	}
+	return stats
+}
+
+// maxHistogramBuckets bounds the response size of /api/items/histogram.
+const maxHistogramBuckets = 1000
+
+type histogramBucket struct {
+	Lower float64 `json:"lower"`
+	Upper float64 `json:"upper"`
+	Count int     `json:"count"`
+}
+
+// Histogram counts item values into buckets. Each bucket covers
+// [lower, upper), except the last, which also includes its upper bound.
+// Values outside explicit edges are counted in Below and Above, so all
+// counts always add up to Total.
+type Histogram struct {
+	Total   int               `json:"total"`
+	Buckets []histogramBucket `json:"buckets"`
+	Below   int               `json:"below"`
+	Above   int               `json:"above"`
+}
+
+// equalWidthEdges returns n+1 edges splitting the range of the item values
+// into n equal-width buckets, or nil if there are no items.
+func equalWidthEdges(items []Item, n int) []float64 {
+	if len(items) == 0 {
+		return nil
+	}
+	stats := computeStats(items)
+	lo, hi := float64(stats.Min), float64(stats.Max)
+	if lo == hi {
+		// Every value is the same; give the single value a bucket to live in.
+		hi = lo + 1
+	}
+	width := (hi - lo) / float64(n)
+	edges := make([]float64, n+1)
+	for i := range edges {
+		edges[i] = lo + float64(i)*width
+	}
+	edges[n] = hi
+	return edges
+}
+
+func computeHistogram(items []Item, edges []float64) Histogram {
+	h := Histogram{Total: len(items), Buckets: []histogramBucket{}}
+	if len(edges) < 2 {
+		return h
+	}
+	for i := 0; i+1 < len(edges); i++ {
+		h.Buckets = append(h.Buckets, histogramBucket{Lower: edges[i], Upper: edges[i+1]})
+	}
+	last := len(h.Buckets) - 1
+	for _, item := range items {
+		v := float64(item.Value)
+		switch {
+		case v < edges[0]:
+			h.Below++
+		case v > edges[len(edges)-1]:
+			h.Above++
+		case v == edges[len(edges)-1]:
+			h.Buckets[last].Count++
+		default:
+			// v belongs to the bucket starting at the last edge <= v.
+			i := sort.SearchFloat64s(edges, v)
+			if i == len(edges) || edges[i] != v {
+				i--
+			}
+			h.Buckets[i].Count++
+		}
+	}
+	return h
+}
+
+func histogramHandler(w http.ResponseWriter, r *http.Request) {
+	if !allowQuery(w, r, "buckets", "edges") {
+		return
+	}
+	query := r.URL.Query()
+	items, _, err := store.list(r.Context())
+	if err != nil {
+		writeStoreError(w, err)
+		return
+	}
+
+	var edges []float64
+	if raw := query.Get("edges"); raw != "" {
+		for _, part := range strings.Split(raw, ",") {
+			edge, err := strconv.ParseFloat(strings.TrimSpace(part), 64)
+			if err != nil || math.IsNaN(edge) || math.IsInf(edge, 0) {
+				writeError(w, http.StatusBadRequest, fmt.Sprintf("Invalid edge %q", part))
+				return
+			}
+			if len(edges) > 0 && edge <= edges[len(edges)-1] {
+				writeError(w, http.StatusBadRequest, "edges must be strictly increasing")
+				return
+			}
+			edges = append(edges, edge)
+		}
+		if len(edges) < 2 || len(edges) > maxHistogramBuckets+1 {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("edges must have between 2 and %d values", maxHistogramBuckets+1))
+			return
+		}
+	} else {
+		n := 10
+		if raw := query.Get("buckets"); raw != "" {
+			n, err = strconv.Atoi(raw)
+			if err != nil || n < 1 || n > maxHistogramBuckets {
+				writeError(w, http.StatusBadRequest, fmt.Sprintf("buckets must be between 1 and %d", maxHistogramBuckets))
+				return
+			}
+		}
+		edges = equalWidthEdges(items, n)
+	}
+
+	writeJSON(w, http.StatusOK, computeHistogram(items, edges))
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+)
+
+func getHistogram(t *testing.T, h http.Handler, query string) Histogram {
+	t.Helper()
+	w := do(h, http.MethodGet, "/api/items/histogram"+query, "")
+	wantStatus(t, w, http.StatusOK)
+	var hist Histogram
+	decode(t, w, &hist)
+	sum := hist.Below + hist.Above
+	for _, b := range hist.Buckets {
+		sum += b.Count
+	}
+	if sum != hist.Total {
+		t.Errorf("%s: counts add up to %d, want total %d", query, sum, hist.Total)
+	}
+	return hist
+}
+
+func TestHistogramEqualWidth(t *testing.T) {
+	h := newTestServer(t)
+	hist := getHistogram(t, h, "?buckets=2")
+	want := []histogramBucket{{Lower: 100, Upper: 200, Count: 1}, {Lower: 200, Upper: 300, Count: 2}}
+	if hist.Total != 3 || len(hist.Buckets) != len(want) {
+		t.Fatalf("histogram = %+v, want 3 items in %+v", hist, want)
+	}
+	for i, b := range hist.Buckets {
+		if b != want[i] {
+			t.Errorf("bucket %d = %+v, want %+v", i, b, want[i])
+		}
+	}
+	if got := len(getHistogram(t, h, "").Buckets); got != 10 {
+		t.Errorf("default histogram has %d buckets, want 10", got)
+	}
+}
+
+func TestHistogramExplicitEdges(t *testing.T) {
+	h := newTestServer(t)
+	hist := getHistogram(t, h, "?edges=0,150,250")
+	if hist.Buckets[0].Count != 1 || hist.Buckets[1].Count != 1 || hist.Above != 1 || hist.Below != 0 {
+		t.Errorf("histogram = %+v, want 1 and 1 in the buckets and 1 above", hist)
+	}
+	for _, query := range []string{"?edges=5", "?edges=1,1", "?edges=a,b", "?buckets=0", "?edges=0,NaN", "?edges=0,Inf", "?edges=-Inf,0,1000", "?edges=0,%2BInf"} {
+		wantStatus(t, do(h, http.MethodGet, "/api/items/histogram"+query, ""), http.StatusBadRequest)
+	}
+}
+
+func TestHistogramEmptyStore(t *testing.T) {
+	h := newTestServer(t)
+	wantStatus(t, do(h, http.MethodPut, "/api/items?confirm=true", "[]"), http.StatusOK)
+	if hist := getHistogram(t, h, "?buckets=5"); hist.Total != 0 || len(hist.Buckets) != 0 {
+		t.Errorf("empty store histogram = %+v, want no buckets", hist)
+	}
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+)
+
+// statsdClient sends metrics to a StatsD server over UDP. Sends are fire and
+// forget: a missing or slow collector never affects request handling.
+type statsdClient struct {
+	conn net.Conn
+}
+
+func newStatsdClient(addr string) (*statsdClient, error) {
+	conn, err := net.Dial("udp", addr)
+	if err != nil {
+		return nil, err
+	}
+	return &statsdClient{conn: conn}, nil
+}
+
+func (c *statsdClient) send(format string, args ...interface{}) {
+	fmt.Fprintf(c.conn, format, args...)
+}
+
+func (c *statsdClient) count(name string, n int) {
+	c.send("codelabs.%s:%d|c", name, n)
+}
+
+func (c *statsdClient) timing(name string, d time.Duration) {
+	c.send("codelabs.%s:%d|ms", name, d.Milliseconds())
+}
+
+func (c *statsdClient) histogram(name string, v int) {
+	c.send("codelabs.%s:%d|h", name, v)
+}
+
+func (c *statsdClient) gauge(name string, v int) {
+	c.send("codelabs.%s:%d|g", name, v)
+}
+
+// statusRecorder captures the status code written by a handler.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	if r.status == 0 {
+		r.status = status
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *statusRecorder) Write(b []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	return r.ResponseWriter.Write(b)
+}
+
+func (r *statusRecorder) Flush() {
+	if f, ok := r.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (r *statusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
+func statsdMiddleware(client *statsdClient, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w}
+		next.ServeHTTP(rec, r)
+
+		status := rec.status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		client.count("requests", 1)
+		client.count(fmt.Sprintf("responses.%d", status), 1)
+		client.timing("request_duration", time.Since(start))
+		if n, err := store.count(r.Context()); err == nil {
+			client.gauge("items", n)
+		}
+	})
+}
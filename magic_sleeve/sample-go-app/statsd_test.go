@@ -0,0 +1,50 @@
+package main
+
+import (
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+// listenStatsd starts a UDP collector and returns its address and a
+// function reading the next metric line sent to it.
+func listenStatsd(t *testing.T) (string, func() string) {
+	t.Helper()
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	next := func() string {
+		buf := make([]byte, 1500)
+		conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+		n, _, err := conn.ReadFrom(buf)
+		if err != nil {
+			t.Fatalf("no metric received: %v", err)
+		}
+		return string(buf[:n])
+	}
+	return conn.LocalAddr().String(), next
+}
+
+func TestStatsdRequestMetrics(t *testing.T) {
+	addr, next := listenStatsd(t)
+	h := newTestServer(t, "-statsd-addr", addr)
+	wantStatus(t, do(h, http.MethodGet, "/api/items/99", ""), http.StatusNotFound)
+
+	var lines []string
+	for i := 0; i < 4; i++ {
+		lines = append(lines, next())
+	}
+	got := strings.Join(lines, "\n")
+	for _, want := range []string{"codelabs.requests:1|c", "codelabs.responses.404:1|c", "codelabs.items:3|g"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("metrics %q do not include %q", lines, want)
+		}
+	}
+	if !strings.Contains(got, "codelabs.request_duration:") {
+		t.Errorf("metrics %q do not include a request timer", lines)
+	}
+}
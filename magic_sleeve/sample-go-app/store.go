@@ -0,0 +1,416 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"log"
+	"maps"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Storage is the item persistence layer. Every method takes the request's
+// context so a backend doing network I/O can give up as soon as the client
+// disconnects or a deadline passes.
+type Storage interface {
+	get(ctx context.Context, id string) (Item, error)
+	getMany(ctx context.Context, ids []string) (found []Item, missing []string, err error)
+	count(ctx context.Context) (int, error)
+	list(ctx context.Context) ([]Item, uint64, error)
+	scan(ctx context.Context, after string, fn func(Item) bool) (revision uint64, err error)
+	create(ctx context.Context, item Item) (Item, error)
+	copy(ctx context.Context, srcID, name string) (Item, error)
+	rename(ctx context.Context, id, newID string) (Item, error)
+	put(ctx context.Context, id string, item Item) (Item, error)
+	patch(ctx context.Context, id string, p itemPatch) (item Item, created bool, err error)
+	delete(ctx context.Context, id string) error
+	replaceAll(ctx context.Context, items []Item) (replaceSummary, error)
+	sync(ctx context.Context, baseRevision uint64, changes []syncChange) (syncResult, error)
+}
+
+// Store is the in-memory Storage. Its operations never block on I/O, so it
+// ignores the contexts it is given.
+type Store struct {
+	items    map[string]Item
+	revision uint64 // bumped on every write
+	// changed holds the revision at which each ID was last written or
+	// deleted, so deletions stay visible to sync clients.
+	changed map[string]uint64
+	// unique maps -unique-on composite keys to the ID holding them.
+	unique map[string]string
+	mu     sync.RWMutex
+}
+
+var store Storage = newStore()
+
+func newStore() *Store {
+	return &Store{
+		items:   make(map[string]Item),
+		changed: make(map[string]uint64),
+		unique:  make(map[string]string),
+	}
+}
+
+var (
+	errNotFound = errors.New("item not found")
+	errIDTaken  = errors.New("an item with that ID already exists")
+)
+
+// seedStore resets the store to the sample data. Startup and /admin/reseed
+// both go through here so they cannot drift apart.
+func seedStore() int {
+	items := []Item{
+		{ID: "1", Name: "Item One", Value: 100},
+		{ID: "2", Name: "Item Two", Value: 200},
+		{ID: "3", Name: "Item Three", Value: 300},
+	}
+	if _, err := store.replaceAll(context.Background(), items); err != nil {
+		log.Printf("Seeding store failed: %v", err)
+	}
+	return len(items)
+}
+
+func (s *Store) get(_ context.Context, id string) (Item, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	item, exists := s.items[id]
+	if !exists {
+		return Item{}, errNotFound
+	}
+	return item, nil
+}
+
+// getMany looks up every ID under a single read lock. Found items keep the
+// order of ids; repeated IDs are only returned once.
+func (s *Store) getMany(_ context.Context, ids []string) ([]Item, []string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	found := make([]Item, 0, len(ids))
+	missing := []string{}
+	seen := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		if item, exists := s.items[id]; exists {
+			found = append(found, item)
+		} else {
+			missing = append(missing, id)
+		}
+	}
+	return found, missing, nil
+}
+
+func (s *Store) count(_ context.Context) (int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.items), nil
+}
+
+// list returns every item, ordered by ID, along with the store revision
+// they reflect.
+func (s *Store) list(_ context.Context) ([]Item, uint64, error) {
+	s.mu.RLock()
+	items := make([]Item, 0, len(s.items))
+	for _, item := range s.items {
+		items = append(items, item)
+	}
+	revision := s.revision
+	s.mu.RUnlock()
+
+	sort.Slice(items, func(i, j int) bool {
+		return idLess(items[i].ID, items[j].ID)
+	})
+	return items, revision, nil
+}
+
+// scan calls fn with each item ordered after the ID after ("" for all of
+// them), in listing order, until fn returns false.
+func (s *Store) scan(ctx context.Context, after string, fn func(Item) bool) (uint64, error) {
+	all, revision, err := s.list(ctx)
+	if err != nil {
+		return 0, err
+	}
+	for _, item := range all {
+		if after != "" && !idLess(after, item.ID) {
+			continue
+		}
+		if !fn(item) {
+			break
+		}
+	}
+	return revision, nil
+}
+
+// listFrom reads the items ordered after the ID after from s one at a time.
+// When ctx's deadline passes it stops and reports partial; it always returns
+// at least one item, so a client paging with the last ID keeps making
+// progress.
+func listFrom(ctx context.Context, s Storage, after string) (items []Item, revision uint64, partial bool, err error) {
+	revision, err = s.scan(ctx, after, func(item Item) bool {
+		if len(items) > 0 && ctx.Err() != nil {
+			partial = true
+			return false
+		}
+		items = append(items, item)
+		return true
+	})
+	if err != nil {
+		return nil, 0, false, err
+	}
+	if partial {
+		if err := ctx.Err(); !errors.Is(err, context.DeadlineExceeded) {
+			return nil, 0, false, err
+		}
+	}
+	return items, revision, partial, nil
+}
+
+// idLess orders numeric IDs numerically, so "2" sorts before "10", and
+// everything else lexically after them.
+func idLess(a, b string) bool {
+	na, errA := strconv.Atoi(a)
+	nb, errB := strconv.Atoi(b)
+	switch {
+	case errA == nil && errB == nil:
+		return na < nb
+	case errA == nil:
+		return true
+	case errB == nil:
+		return false
+	}
+	return a < b
+}
+
+// create validates and stores item under a newly assigned ID unless it
+// already has one. A client-supplied ID must be free: overwriting goes
+// through put, which honors locks and -immutable-fields. With -auto-name an
+// empty name is filled in from the ID.
+func (s *Store) create(_ context.Context, item Item) (Item, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	item = normalizeItem(item)
+	if item.ID == "" {
+		item.ID = s.nextIDLocked()
+	} else if err := validateID(item.ID); err != nil {
+		return Item{}, &validationError{err: err}
+	} else if _, taken := s.items[item.ID]; taken {
+		return Item{}, errIDTaken
+	}
+	if config.AutoName && strings.TrimSpace(item.Name) == "" {
+		item.Name = "Item-" + item.ID
+	}
+	if err := validateItem(item); err != nil {
+		return Item{}, &validationError{err: err}
+	}
+	return s.putLocked(item)
+}
+
+// copy duplicates the item stored under srcID into a new ID, optionally
+// renaming it. The copy is validated like a newly created item, since the
+// new name comes straight from the client.
+func (s *Store) copy(_ context.Context, srcID, name string) (Item, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	src, exists := s.items[srcID]
+	if !exists {
+		return Item{}, errNotFound
+	}
+	dup := src
+	dup.ID = s.nextIDLocked()
+	if name != "" {
+		dup.Name = name
+	}
+	dup = normalizeItem(dup)
+	if err := validateItem(dup); err != nil {
+		return Item{}, &validationError{err: err}
+	}
+	return s.putLocked(dup)
+}
+
+// rename moves the item stored under id to newID in one step. The item keeps
+// its content and created_at; to sync clients it looks like a delete of id
+// and a create of newID. Renaming is deliberately not subject to
+// -immutable-fields, which govern updates.
+func (s *Store) rename(_ context.Context, id, newID string) (Item, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	item, exists := s.items[id]
+	if !exists {
+		return Item{}, errNotFound
+	}
+	if _, taken := s.items[newID]; taken {
+		return Item{}, errIDTaken
+	}
+
+	// Dropping the old ID first releases its -unique-on keys, so the item
+	// cannot conflict with itself.
+	s.deleteLocked(id)
+	createdAt := item.CreatedAt
+	item.ID = newID
+	item, err := s.putLocked(item)
+	if err != nil {
+		return Item{}, err
+	}
+	item.CreatedAt = createdAt
+	s.items[newID] = item
+	locks.move(id, newID)
+	return item, nil
+}
+
+// nextIDLocked returns an unused ID from the -id-format generator.
+func (s *Store) nextIDLocked() string {
+	return idGenerator.newID(len(s.items), func(id string) bool {
+		_, taken := s.items[id]
+		return taken
+	})
+}
+
+// put stores item under id, replacing any existing item. Replacing an item
+// must leave its -immutable-fields unchanged.
+func (s *Store) put(_ context.Context, id string, item Item) (Item, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := validateID(id); err != nil {
+		return Item{}, &validationError{err: err}
+	}
+	item = normalizeItem(item)
+	if err := validateItem(item); err != nil {
+		return Item{}, &validationError{err: err}
+	}
+	if existing, exists := s.items[id]; exists {
+		if field := immutableViolation(existing, item); field != "" {
+			return Item{}, &immutableError{field: field}
+		}
+	}
+	item.ID = id
+	return s.putLocked(item)
+}
+
+// putLocked writes item, stamping it with server-controlled timestamps and
+// revision, and returns exactly what was stored. CreatedAt survives updates
+// to an existing ID. Nothing is written if the item would violate
+// -unique-on.
+func (s *Store) putLocked(item Item) (Item, error) {
+	item = normalizeItem(item)
+	if err := s.checkUniqueLocked(item); err != nil {
+		return Item{}, err
+	}
+
+	now := time.Now().UTC()
+	if existing, exists := s.items[item.ID]; exists {
+		item.CreatedAt = existing.CreatedAt
+		s.unindexLocked(existing)
+	} else {
+		item.CreatedAt = now
+	}
+	item.UpdatedAt = now
+	item.Checksum = itemChecksum(item)
+
+	s.revision++
+	item.revision = s.revision
+	s.changed[item.ID] = s.revision
+	s.items[item.ID] = item
+	s.indexLocked(item)
+	return s.items[item.ID], nil
+}
+
+func (s *Store) delete(_ context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, exists := s.items[id]; !exists {
+		return errNotFound
+	}
+	s.deleteLocked(id)
+	return nil
+}
+
+func (s *Store) deleteLocked(id string) {
+	if item, exists := s.items[id]; exists {
+		s.unindexLocked(item)
+	}
+	delete(s.items, id)
+	s.revision++
+	s.changed[id] = s.revision
+}
+
+type replaceSummary struct {
+	Created int `json:"created"`
+	Updated int `json:"updated"`
+	Deleted int `json:"deleted"`
+}
+
+// replaceAll makes items the entire contents of the store in one step:
+// anything not in items is deleted. Either every item is written or, on a
+// uniqueness conflict within items, nothing is.
+func (s *Store) replaceAll(_ context.Context, items []Item) (replaceSummary, error) {
+	// Uniqueness is checked on the stored form, so names that only differ
+	// in surrounding whitespace conflict here rather than in putLocked.
+	normalized := make([]Item, len(items))
+	for i, item := range items {
+		normalized[i] = normalizeItem(item)
+	}
+	items = normalized
+	if err := checkUniqueSet(items); err != nil {
+		return replaceSummary{}, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Keep the old state so a write failing halfway through can be undone
+	// instead of leaving the store half replaced.
+	oldItems, oldChanged, oldUnique, oldRevision := maps.Clone(s.items), maps.Clone(s.changed), maps.Clone(s.unique), s.revision
+	rollback := func() {
+		s.items, s.changed, s.unique, s.revision = oldItems, oldChanged, oldUnique, oldRevision
+	}
+
+	var summary replaceSummary
+	keep := make(map[string]bool, len(items))
+	for _, item := range items {
+		keep[item.ID] = true
+	}
+	for id := range s.items {
+		if !keep[id] {
+			s.deleteLocked(id)
+			summary.Deleted++
+		}
+	}
+
+	// Every remaining item is about to be rewritten, and items has already
+	// been checked against itself, so the index can start from scratch.
+	s.unique = make(map[string]string)
+	for _, item := range items {
+		if _, exists := s.items[item.ID]; exists {
+			summary.Updated++
+		} else {
+			summary.Created++
+		}
+		if _, err := s.putLocked(item); err != nil {
+			rollback()
+			return replaceSummary{}, err
+		}
+	}
+	return summary, nil
+}
+
+// itemChecksum returns a CRC32 over the item's client-controlled content.
+// Timestamps and the checksum itself are left out so identical content
+// always produces the same checksum.
+func itemChecksum(item Item) string {
+	canonical, _ := json.Marshal(struct {
+		ID    string          `json:"id"`
+		Name  string          `json:"name"`
+		Value int             `json:"value"`
+		Tags  []string        `json:"tags"`
+		Data  json.RawMessage `json:"data"`
+	}{item.ID, item.Name, item.Value, item.Tags, item.Data})
+	return fmt.Sprintf("%08x", crc32.ChecksumIEEE(canonical))
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func putItem(t *testing.T, h http.Handler, id, body string) Item {
+	t.Helper()
+	w := do(h, http.MethodPut, "/api/items/"+id, body)
+	wantStatus(t, w, http.StatusOK)
+	var item Item
+	decode(t, w, &item)
+	return item
+}
+
+func TestChecksum(t *testing.T) {
+	h := newTestServer(t)
+	first := putItem(t, h, "1", `{"name":"Same","value":5,"tags":["a"],"checksum":"ffffffff"}`)
+	if first.Checksum == "" || first.Checksum == "ffffffff" {
+		t.Fatalf("checksum = %q, want one computed by the server", first.Checksum)
+	}
+
+	// Rewriting identical content bumps updated_at but not the checksum.
+	again := putItem(t, h, "1", `{"name":"Same","value":5,"tags":["a"]}`)
+	if again.Checksum != first.Checksum {
+		t.Errorf("checksum changed from %s to %s for identical content", first.Checksum, again.Checksum)
+	}
+
+	for _, body := range []string{
+		`{"name":"Other","value":5,"tags":["a"]}`,
+		`{"name":"Same","value":6,"tags":["a"]}`,
+		`{"name":"Same","value":5,"tags":["b"]}`,
+	} {
+		if changed := putItem(t, h, "1", body); changed.Checksum == first.Checksum {
+			t.Errorf("checksum stayed %s after writing %s", first.Checksum, body)
+		}
+	}
+}
+
+// remoteStore is a Storage that behaves like a networked backend: every
+// call takes latency to answer unless its context ends first.
+type remoteStore struct {
+	*Store
+	latency time.Duration
+}
+
+func (s remoteStore) wait(ctx context.Context) error {
+	select {
+	case <-time.After(s.latency):
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (s remoteStore) get(ctx context.Context, id string) (Item, error) {
+	if err := s.wait(ctx); err != nil {
+		return Item{}, err
+	}
+	return s.Store.get(ctx, id)
+}
+
+func TestCanceledContextStopsStorage(t *testing.T) {
+	h := newTestServer(t)
+	store = remoteStore{Store: store.(*Store), latency: 10 * time.Second}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	start := time.Now()
+	if _, err := store.get(ctx, "1"); !errors.Is(err, context.Canceled) {
+		t.Errorf("get returned %v, want context.Canceled", err)
+	}
+	w := serve(h, newRequest(http.MethodGet, "/api/items/1", "").WithContext(ctx))
+	wantStatus(t, w, http.StatusServiceUnavailable)
+	if took := time.Since(start); took > time.Second {
+		t.Errorf("canceled calls took %s", took)
+	}
+}
+
+func TestWritesReturnCanonicalItem(t *testing.T) {
+	h := newTestServer(t)
+	w := do(h, http.MethodPost, "/api/items", `{"name":"  Padded  ","tags":[" a ","b "],"data":{ "k" : 1 },"checksum":"bogus"}`)
+	wantStatus(t, w, http.StatusCreated)
+	var created Item
+	decode(t, w, &created)
+	if created.ID == "" || created.Name != "Padded" || created.Tags[0] != "a" || created.Tags[1] != "b" || string(created.Data) != `{"k":1}` {
+		t.Errorf("created = %+v, want the trimmed, compacted form", created)
+	}
+	if created.CreatedAt.IsZero() || created.UpdatedAt.IsZero() || created.Checksum == "bogus" {
+		t.Errorf("created = %+v, want server timestamps and checksum", created)
+	}
+
+	// The response is exactly what a read returns.
+	read := do(h, http.MethodGet, "/api/items/"+created.ID, "")
+	if strings.TrimSpace(read.Body.String()) != strings.TrimSpace(w.Body.String()) {
+		t.Errorf("POST returned\n%s\nbut GET returns\n%s", w.Body.String(), read.Body.String())
+	}
+
+	w = do(h, http.MethodPut, "/api/items/"+created.ID, `{"name":" Updated ","value":2}`)
+	wantStatus(t, w, http.StatusOK)
+	read = do(h, http.MethodGet, "/api/items/"+created.ID, "")
+	if strings.TrimSpace(read.Body.String()) != strings.TrimSpace(w.Body.String()) {
+		t.Errorf("PUT returned\n%s\nbut GET returns\n%s", w.Body.String(), read.Body.String())
+	}
+}
+
+func TestReplaceAllNormalizesBeforeChecking(t *testing.T) {
+	h := newTestServer(t, "-unique-on", "name")
+	// The names only differ in whitespace, so they collide once trimmed.
+	w := do(h, http.MethodPut, "/api/items?confirm=true", `[{"id":"1","name":"Same"},{"id":"2","name":" Same "}]`)
+	wantStatus(t, w, http.StatusConflict)
+	if got := strings.Join(listIDs(t, h, "/api/items"), ","); got != "1,2,3" {
+		t.Errorf("a rejected replace changed the store to %q", got)
+	}
+
+	// Names that are blank once trimmed fail validation.
+	wantStatus(t, do(h, http.MethodPut, "/api/items?confirm=true", `[{"id":"1","name":"   "}]`), http.StatusBadRequest)
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+const (
+	syncOpPut    = "put"
+	syncOpDelete = "delete"
+)
+
+type syncChange struct {
+	Op   string `json:"op"`
+	ID   string `json:"id,omitempty"`
+	Item *Item  `json:"item,omitempty"`
+}
+
+type syncRequest struct {
+	BaseRevision uint64            `json:"base_revision"`
+	Changes      batch[syncChange] `json:"changes"`
+}
+
+type syncConflict struct {
+	ID     string `json:"id"`
+	Reason string `json:"reason"`
+	// Current is the server's version of the item, or nil if the server
+	// deleted it.
+	Current *Item `json:"current"`
+}
+
+type syncResult struct {
+	Revision  uint64         `json:"revision"`
+	Applied   []syncChange   `json:"applied"`
+	Conflicts []syncConflict `json:"conflicts"`
+}
+
+// sync applies changes made by a client whose view of the store is
+// baseRevision. A change to an ID the server modified after baseRevision is
+// reported as a conflict instead of overwriting the server's version.
+func (s *Store) sync(_ context.Context, baseRevision uint64, changes []syncChange) (syncResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	result := syncResult{Applied: []syncChange{}, Conflicts: []syncConflict{}}
+	for _, change := range changes {
+		if change.ID != "" && s.changed[change.ID] > baseRevision {
+			conflict := syncConflict{ID: change.ID, Reason: "Modified on the server since base_revision"}
+			if current, exists := s.items[change.ID]; exists {
+				conflict.Current = &current
+			}
+			result.Conflicts = append(result.Conflicts, conflict)
+			continue
+		}
+
+		if change.ID != "" && !locks.allows(change.ID, "") {
+			conflict := syncConflict{ID: change.ID, Reason: "Item is locked"}
+			if current, exists := s.items[change.ID]; exists {
+				conflict.Current = &current
+			}
+			result.Conflicts = append(result.Conflicts, conflict)
+			continue
+		}
+
+		switch change.Op {
+		case syncOpPut:
+			item := *change.Item
+			item.ID = change.ID
+			if item.ID == "" {
+				item.ID = s.nextIDLocked()
+			}
+			item, err := s.putLocked(item)
+			if err != nil {
+				conflict := syncConflict{ID: change.ID, Reason: err.Error()}
+				if current, exists := s.items[change.ID]; exists {
+					conflict.Current = &current
+				}
+				result.Conflicts = append(result.Conflicts, conflict)
+				continue
+			}
+			result.Applied = append(result.Applied, syncChange{Op: syncOpPut, ID: item.ID, Item: &item})
+		case syncOpDelete:
+			if _, exists := s.items[change.ID]; exists {
+				s.deleteLocked(change.ID)
+			}
+			result.Applied = append(result.Applied, syncChange{Op: syncOpDelete, ID: change.ID})
+		}
+	}
+	result.Revision = s.revision
+	return result, nil
+}
+
+func syncItemsHandler(w http.ResponseWriter, r *http.Request) {
+	if !allowQuery(w, r) {
+		return
+	}
+	var req syncRequest
+	if err := decodeJSON(r, &req); err != nil {
+		writeDecodeError(w, err, "Invalid JSON")
+		return
+	}
+	start := time.Now()
+
+	seen := make(map[string]bool, len(req.Changes))
+	for i := range req.Changes {
+		change := &req.Changes[i]
+		if change.ID == "" && change.Item != nil {
+			change.ID = change.Item.ID
+		}
+		if change.ID != "" {
+			if seen[change.ID] {
+				writeError(w, http.StatusBadRequest, fmt.Sprintf("Change %d: duplicate id %q", i, change.ID))
+				return
+			}
+			seen[change.ID] = true
+		}
+
+		switch change.Op {
+		case syncOpPut:
+			if change.Item == nil {
+				writeError(w, http.StatusBadRequest, fmt.Sprintf("Change %d: item is required for put", i))
+				return
+			}
+			*change.Item = normalizeItem(*change.Item)
+			if change.ID != "" {
+				if err := validateID(change.ID); err != nil {
+					writeError(w, http.StatusBadRequest, fmt.Sprintf("Change %d: %v", i, err))
+					return
+				}
+			}
+			if err := validateItem(*change.Item); err != nil {
+				writeError(w, http.StatusBadRequest, fmt.Sprintf("Change %d: %v", i, err))
+				return
+			}
+		case syncOpDelete:
+			if change.ID == "" {
+				writeError(w, http.StatusBadRequest, fmt.Sprintf("Change %d: id is required for delete", i))
+				return
+			}
+		default:
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("Change %d: op must be put or delete", i))
+			return
+		}
+	}
+
+	result, err := store.sync(r.Context(), req.BaseRevision, req.Changes)
+	if err != nil {
+		writeStoreError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, struct {
+		syncResult
+		batchTiming
+	}{result, finishBatch("sync", len(req.Changes), start)})
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"testing"
+)
+
+// currentRevision returns the store revision reported by the listing.
+func currentRevision(t *testing.T, h http.Handler) uint64 {
+	t.Helper()
+	w := do(h, http.MethodGet, "/api/items", "")
+	rev, err := strconv.ParseUint(w.Header().Get("X-Revision"), 10, 64)
+	if err != nil {
+		t.Fatalf("X-Revision %q: %v", w.Header().Get("X-Revision"), err)
+	}
+	return rev
+}
+
+func syncItems(t *testing.T, h http.Handler, body string) syncResult {
+	t.Helper()
+	w := do(h, http.MethodPost, "/api/items/sync", body)
+	wantStatus(t, w, http.StatusOK)
+	var result syncResult
+	decode(t, w, &result)
+	return result
+}
+
+func TestSyncCleanApply(t *testing.T) {
+	h := newTestServer(t)
+	base := currentRevision(t, h)
+
+	result := syncItems(t, h, fmt.Sprintf(`{"base_revision":%d,"changes":[
+		{"op":"put","item":{"id":"1","name":"Offline edit","value":1}},
+		{"op":"put","item":{"name":"Offline create"}},
+		{"op":"delete","id":"2"}]}`, base))
+	if len(result.Applied) != 3 || len(result.Conflicts) != 0 {
+		t.Fatalf("applied %d, conflicts %v; want 3 applied and none", len(result.Applied), result.Conflicts)
+	}
+	if result.Revision <= base {
+		t.Errorf("revision %d did not advance past %d", result.Revision, base)
+	}
+
+	var item Item
+	decode(t, do(h, http.MethodGet, "/api/items/1", ""), &item)
+	if item.Name != "Offline edit" {
+		t.Errorf("item 1 name = %q, want the synced edit", item.Name)
+	}
+	wantStatus(t, do(h, http.MethodGet, "/api/items/2", ""), http.StatusNotFound)
+	if created := result.Applied[1].ID; created == "" {
+		t.Error("the created item was not given an ID")
+	}
+}
+
+func TestSyncReportsConflict(t *testing.T) {
+	h := newTestServer(t)
+	base := currentRevision(t, h)
+	wantStatus(t, do(h, http.MethodPut, "/api/items/1", `{"name":"Server edit"}`), http.StatusOK)
+
+	result := syncItems(t, h, fmt.Sprintf(`{"base_revision":%d,"changes":[
+		{"op":"put","item":{"id":"1","name":"Client edit"}},
+		{"op":"put","item":{"id":"3","name":"Client edit"}}]}`, base))
+	if len(result.Conflicts) != 1 || result.Conflicts[0].ID != "1" {
+		t.Fatalf("conflicts = %+v, want one for item 1", result.Conflicts)
+	}
+	if current := result.Conflicts[0].Current; current == nil || current.Name != "Server edit" {
+		t.Errorf("conflict carries %+v, want the server's version", current)
+	}
+	if len(result.Applied) != 1 || result.Applied[0].ID != "3" {
+		t.Errorf("applied = %+v, want only item 3", result.Applied)
+	}
+
+	var item Item
+	decode(t, do(h, http.MethodGet, "/api/items/1", ""), &item)
+	if item.Name != "Server edit" {
+		t.Errorf("item 1 was overwritten with %q", item.Name)
+	}
+}
+
+func TestSyncValidatesItemsWithoutID(t *testing.T) {
+	h := newTestServer(t, "-max-name-length", "8")
+	before := listIDs(t, h, "/api/items")
+	for _, item := range []string{`{"name":""}`, `{"name":"Far too long"}`, `{"name":"Ok","data":{"a":}}`} {
+		body := fmt.Sprintf(`{"base_revision":%d,"changes":[{"op":"put","item":%s}]}`, currentRevision(t, h), item)
+		wantStatus(t, do(h, http.MethodPost, "/api/items/sync", body), http.StatusBadRequest)
+	}
+	body := fmt.Sprintf(`{"base_revision":%d,"changes":[{"op":"put","item":{"name":"Ok"}}]}`, currentRevision(t, h))
+	wantStatus(t, do(h, http.MethodPost, "/api/items/sync", body), http.StatusOK)
+	if after := listIDs(t, h, "/api/items"); len(after) != len(before)+1 {
+		t.Errorf("items %v after syncing, want only the valid one added to %v", after, before)
+	}
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+var uniqueFields = map[string]bool{"name": true, "value": true, "tags": true}
+
+type uniqueError struct {
+	conflictID string
+}
+
+func (e *uniqueError) Error() string {
+	return fmt.Sprintf("An item with the same %s already exists (id %q)", strings.Join(config.UniqueOn, " and "), e.conflictID)
+}
+
+// uniqueKeys returns the composite keys item occupies under -unique-on.
+// Tags are multi-valued, so an item gets one key per tag: two items conflict
+// when they agree on every other field and share at least one tag. An item
+// without tags has no keys when tags are part of the constraint.
+func uniqueKeys(item Item) []string {
+	if len(config.UniqueOn) == 0 {
+		return nil
+	}
+	keys := []string{""}
+	for _, field := range config.UniqueOn {
+		var values []string
+		switch field {
+		case "name":
+			values = []string{item.Name}
+		case "value":
+			values = []string{strconv.Itoa(item.Value)}
+		case "tags":
+			values = item.Tags
+		}
+		next := make([]string, 0, len(keys)*len(values))
+		for _, key := range keys {
+			for _, v := range values {
+				next = append(next, key+"\x00"+v)
+			}
+		}
+		keys = next
+	}
+	return keys
+}
+
+func (s *Store) checkUniqueLocked(item Item) error {
+	for _, key := range uniqueKeys(item) {
+		if owner, taken := s.unique[key]; taken && owner != item.ID {
+			return &uniqueError{conflictID: owner}
+		}
+	}
+	return nil
+}
+
+func (s *Store) indexLocked(item Item) {
+	for _, key := range uniqueKeys(item) {
+		s.unique[key] = item.ID
+	}
+}
+
+func (s *Store) unindexLocked(item Item) {
+	for _, key := range uniqueKeys(item) {
+		if s.unique[key] == item.ID {
+			delete(s.unique, key)
+		}
+	}
+}
+
+// checkUniqueSet reports the first uniqueness conflict among items, which
+// are about to become the entire contents of the store.
+func checkUniqueSet(items []Item) error {
+	owners := make(map[string]string)
+	for _, item := range items {
+		for _, key := range uniqueKeys(item) {
+			if owner, taken := owners[key]; taken && owner != item.ID {
+				return &uniqueError{conflictID: owner}
+			}
+			owners[key] = item.ID
+		}
+	}
+	return nil
+}
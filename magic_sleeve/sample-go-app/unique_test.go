@@ -0,0 +1,24 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestUniqueOnCompositeKey(t *testing.T) {
+	h := newTestServer(t, "-unique-on", "name,tags")
+	w := do(h, http.MethodPost, "/api/items", `{"name":"Widget","tags":["red","large"]}`)
+	wantStatus(t, w, http.StatusCreated)
+	var widget Item
+	decode(t, w, &widget)
+
+	// Same name and one shared tag.
+	wantStatus(t, do(h, http.MethodPost, "/api/items", `{"name":"Widget","tags":["large","blue"]}`), http.StatusConflict)
+
+	// Sharing just one component is fine.
+	wantStatus(t, do(h, http.MethodPost, "/api/items", `{"name":"Gadget","tags":["red"]}`), http.StatusCreated)
+	wantStatus(t, do(h, http.MethodPost, "/api/items", `{"name":"Widget","tags":["blue"]}`), http.StatusCreated)
+
+	// An item never conflicts with itself.
+	wantStatus(t, do(h, http.MethodPut, "/api/items/"+widget.ID, `{"name":"Widget","tags":["red","large"],"value":7}`), http.StatusOK)
+}
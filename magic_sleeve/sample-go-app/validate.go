@@ -0,0 +1,139 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+type validationError struct {
+	err error
+}
+
+func (e *validationError) Error() string {
+	return e.err.Error()
+}
+
+// normalizeItem returns item in the canonical form it is stored in, with
+// surrounding whitespace trimmed from the name and tags and data compacted.
+// A null data is the same as none.
+func normalizeItem(item Item) Item {
+	item.Name = strings.TrimSpace(item.Name)
+	if item.Data != nil {
+		var buf bytes.Buffer
+		if err := json.Compact(&buf, item.Data); err == nil {
+			item.Data = buf.Bytes()
+		}
+		if string(item.Data) == "null" {
+			item.Data = nil
+		}
+	}
+	if item.Tags != nil {
+		tags := make([]string, len(item.Tags))
+		for i, tag := range item.Tags {
+			tags[i] = strings.TrimSpace(tag)
+		}
+		item.Tags = tags
+	}
+	return item
+}
+
+func validateItem(item Item) error {
+	if strings.TrimSpace(item.Name) == "" {
+		return errors.New("name is required")
+	}
+	if !utf8.ValidString(item.Name) {
+		return errors.New("name is not valid UTF-8")
+	}
+	if config.MaxNameLength > 0 && len(item.Name) > config.MaxNameLength {
+		return fmt.Errorf("name exceeds maximum length of %d bytes", config.MaxNameLength)
+	}
+	for _, tag := range item.Tags {
+		if !utf8.ValidString(tag) {
+			return errors.New("tags must be valid UTF-8")
+		}
+	}
+	if item.Data != nil {
+		if !json.Valid(item.Data) {
+			return errors.New("data is not valid JSON")
+		}
+		if config.MaxDataSize > 0 && len(item.Data) > config.MaxDataSize {
+			return fmt.Errorf("data exceeds maximum size of %d bytes", config.MaxDataSize)
+		}
+	}
+	return nil
+}
+
+// itemSubresources are the /api/items/{id}/... suffixes routed to their own
+// handlers, so an ID ending in one of them could never be addressed.
+var itemSubresources = []string{"/lock", "/copy", "/rename"}
+
+// validateID checks that id can be used in item URLs: it must be printable
+// without surrounding whitespace, and not shadowed by a collection endpoint
+// or subresource.
+func validateID(id string) error {
+	if id == "" {
+		return errors.New("id is required")
+	}
+	if !utf8.ValidString(id) {
+		return errors.New("id is not valid UTF-8")
+	}
+	if strings.TrimSpace(id) != id {
+		return errors.New("id must not start or end with whitespace")
+	}
+	if strings.IndexFunc(id, unicode.IsControl) >= 0 {
+		return errors.New("id must not contain control characters")
+	}
+	if collectionViews[id] || id == "sync" || id == "get" || strings.HasPrefix(id, "/") || strings.HasSuffix(id, "/") {
+		return fmt.Errorf("id %q is reserved", id)
+	}
+	for _, suffix := range itemSubresources {
+		if strings.HasSuffix(id, suffix) {
+			return fmt.Errorf("id must not end in %q", suffix)
+		}
+	}
+	return nil
+}
+
+var immutableFieldNames = map[string]bool{"id": true, "name": true, "value": true, "tags": true, "data": true, "created_at": true}
+
+type immutableError struct {
+	field string
+}
+
+func (e *immutableError) Error() string {
+	return fmt.Sprintf("%s is immutable and cannot be changed", e.field)
+}
+
+// immutableViolation returns the first of -immutable-fields that update
+// would change on stored, or "" if there is none. Server-assigned fields
+// (id, created_at) only count as changed when the client actually sent a
+// value for them.
+func immutableViolation(stored, update Item) string {
+	for _, field := range config.ImmutableFields {
+		var changed bool
+		switch field {
+		case "id":
+			changed = update.ID != "" && update.ID != stored.ID
+		case "created_at":
+			changed = !update.CreatedAt.IsZero() && !update.CreatedAt.Equal(stored.CreatedAt)
+		case "name":
+			changed = update.Name != stored.Name
+		case "value":
+			changed = update.Value != stored.Value
+		case "tags":
+			changed = !slices.Equal(update.Tags, stored.Tags)
+		case "data":
+			changed = !bytes.Equal(update.Data, stored.Data)
+		}
+		if changed {
+			return field
+		}
+	}
+	return ""
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestNameLengthLimit(t *testing.T) {
+	h := newTestServer(t, "-max-name-length", "10")
+	wantStatus(t, do(h, http.MethodPost, "/api/items", `{"name":"`+strings.Repeat("n", 10)+`"}`), http.StatusCreated)
+
+	for _, method := range []string{http.MethodPost, http.MethodPut} {
+		target := "/api/items"
+		if method == http.MethodPut {
+			target = "/api/items/1"
+		}
+		w := do(h, method, target, `{"name":"`+strings.Repeat("n", 11)+`"}`)
+		wantStatus(t, w, http.StatusBadRequest)
+		if !strings.Contains(w.Body.String(), "name exceeds maximum length of 10 bytes") {
+			t.Errorf("%s: error %s does not name the field and limit", method, w.Body.String())
+		}
+	}
+}
+
+func TestInvalidUTF8Rejected(t *testing.T) {
+	h := newTestServer(t)
+	for _, body := range []string{
+		"{\"name\":\"bad \xff name\"}",
+		"{\"name\":\"ok\",\"tags\":[\"bad \xc3\x28\"]}",
+	} {
+		w := do(h, http.MethodPost, "/api/items", body)
+		wantStatus(t, w, http.StatusBadRequest)
+		if !strings.Contains(w.Body.String(), "UTF-8") {
+			t.Errorf("error %s does not mention UTF-8", w.Body.String())
+		}
+	}
+	if got := len(listIDs(t, h, "/api/items")); got != 3 {
+		t.Errorf("store holds %d items, want the 3 seeded ones", got)
+	}
+
+	// Items built in-process are checked too.
+	if err := validateItem(Item{Name: "bad \xff"}); err == nil {
+		t.Error("validateItem accepted an invalid UTF-8 name")
+	}
+	if err := validateItem(Item{Name: "ok", Tags: []string{"\xff"}}); err == nil {
+		t.Error("validateItem accepted an invalid UTF-8 tag")
+	}
+}
+
+func TestImmutableFieldsDefault(t *testing.T) {
+	h := newTestServer(t)
+	w := do(h, http.MethodPut, "/api/items/1", `{"id":"7","name":"Item One"}`)
+	wantStatus(t, w, http.StatusConflict)
+	if !strings.Contains(w.Body.String(), "id is immutable") {
+		t.Errorf("error %s does not name the id field", w.Body.String())
+	}
+	wantStatus(t, do(h, http.MethodPut, "/api/items/1", `{"name":"Item One","created_at":"2001-01-01T00:00:00Z"}`), http.StatusConflict)
+	// POST cannot replace an existing item behind PUT's back.
+	wantStatus(t, do(h, http.MethodPost, "/api/items", `{"id":"1","name":"Item One","created_at":"2001-01-01T00:00:00Z"}`), http.StatusConflict)
+
+	// Mutable fields change, and echoing the stored id is not a change.
+	wantStatus(t, do(h, http.MethodPut, "/api/items/1", `{"id":"1","name":"Renamed","value":5}`), http.StatusOK)
+	wantStatus(t, do(h, http.MethodPatch, "/api/items/1", `{"value":6}`), http.StatusOK)
+}
+
+func TestImmutableFieldsConfigured(t *testing.T) {
+	h := newTestServer(t, "-immutable-fields", "name")
+	for _, method := range []string{http.MethodPut, http.MethodPatch} {
+		w := do(h, method, "/api/items/1", `{"name":"Renamed","value":100}`)
+		wantStatus(t, w, http.StatusConflict)
+		if !strings.Contains(w.Body.String(), "name is immutable") {
+			t.Errorf("%s: error %s does not name the name field", method, w.Body.String())
+		}
+	}
+	wantStatus(t, do(h, http.MethodPatch, "/api/items/1", `{"value":5}`), http.StatusOK)
+}